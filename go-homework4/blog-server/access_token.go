@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 个人访问令牌：给脚本和自动化用的长期令牌 ======================
+// 用户自己创建，带名字、scope和可选的过期时间；明文只在创建时返回一次，数据库只存sha256
+// 请求头和JWT一样用 Authorization: Bearer <token>，以 blog_pat_ 开头的按个人访问令牌校验，每次请求都查库，吊销立即生效
+// 每个接口需要的scope见 requiredScope：GET接口需要read，写文章和写评论的接口分别需要 write:posts、write:comments，
+// 其它接口（令牌管理、登出、关注、表态、管理接口等）不接受个人访问令牌，只能用登录拿到的JWT
+
+const PATPrefix = "blog_pat_"
+
+const (
+	ScopeRead          = "read"
+	ScopeWritePosts    = "write:posts"
+	ScopeWriteComments = "write:comments"
+)
+
+// Scopes 全部scope，响应里也按这个顺序排列
+var Scopes = []string{ScopeRead, ScopeWritePosts, ScopeWriteComments}
+
+const (
+	maxPersonalTokens = 20                 // 每个用户最多几个没有吊销的令牌
+	touchInterval     = time.Minute        // 最近使用时间最多每分钟写一次库
+	patHintLength     = len(PATPrefix) + 4 // 列表里展示的令牌开头，方便用户认出是哪个
+)
+
+// routeScopes 非GET接口需要的scope；值为空表示不接受个人访问令牌，没列出的非GET接口同样不接受
+var routeScopes = map[string]string{
+	"POST /api/posts":                            ScopeWritePosts,
+	"PUT /api/posts/:id":                         ScopeWritePosts,
+	"DELETE /api/posts/:id":                      ScopeWritePosts,
+	"POST /api/posts/:id/publish":                ScopeWritePosts,
+	"POST /api/posts/:id/unpublish":              ScopeWritePosts,
+	"POST /api/posts/:id/revisions/:rev/restore": ScopeWritePosts,
+	"POST /api/uploads":                          ScopeWritePosts,
+	"DELETE /api/attachments/:id":                ScopeWritePosts,
+	"POST /api/comments":                         ScopeWriteComments,
+	"PUT /api/comments/:id":                      ScopeWriteComments,
+	"DELETE /api/comments/:id":                   ScopeWriteComments,
+	"GET /api/access-tokens":                     "", // 令牌不能用来管理令牌
+}
+
+// requiredScope 个人访问令牌访问这个接口需要的scope，返回空表示不接受个人访问令牌
+func requiredScope(method, route string) string {
+	if scope, ok := routeScopes[method+" "+route]; ok {
+		return scope
+	}
+	if method == http.MethodGet && !strings.HasPrefix(route, "/api/admin/") {
+		return ScopeRead
+	}
+	return ""
+}
+
+// PersonalAccessToken 个人访问令牌表
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primarykey"`
+	UserID     uint       `gorm:"index;not null"`
+	Name       string     `gorm:"not null;type:varchar(100)"`
+	TokenHash  string     `gorm:"uniqueIndex;not null;type:varchar(64)"` // 令牌的sha256，数据库不存明文
+	Hint       string     `gorm:"not null;type:varchar(20)"`             // 令牌的开头几位
+	Scopes     string     `gorm:"not null;type:varchar(100)"`            // 空格分隔，如 "read write:posts"
+	ExpiresAt  *time.Time // 为空表示永不过期
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// HasScope 令牌是否有这个scope
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range strings.Fields(t.Scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// PersonalTokenRequest 创建个人访问令牌的请求体
+type PersonalTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=read write:posts write:comments"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为空表示永不过期
+}
+
+// PersonalTokenView 响应里的个人访问令牌，不含令牌本身
+type PersonalTokenView struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"` // 令牌的开头几位，如 blog_pat_AbCd
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// PersonalTokenCreated 创建令牌的响应，token只在这里出现一次
+type PersonalTokenCreated struct {
+	PersonalTokenView
+	Token string `json:"token"`
+}
+
+func personalTokenView(t *PersonalAccessToken) PersonalTokenView {
+	return PersonalTokenView{
+		ID:         t.ID,
+		Name:       t.Name,
+		Hint:       t.Hint,
+		Scopes:     strings.Fields(t.Scopes),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+// authenticatePAT 校验个人访问令牌和当前接口需要的scope，通过后把用户信息存入上下文
+// 用户信息每次从数据库读，修改角色后立即生效
+func (s *Server) authenticatePAT(c *gin.Context, token string) error {
+	ctx := c.Request.Context()
+	now := time.Now()
+	t, err := s.PersonalTokens.FindByHash(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) || (err == nil && (t.RevokedAt != nil || (t.ExpiresAt != nil && !t.ExpiresAt.After(now)))) {
+		return errTokenInvalid
+	}
+	if err != nil {
+		log.Errorf("查询个人访问令牌失败: %v", err)
+		return errInternal
+	}
+
+	scope := requiredScope(c.Request.Method, c.FullPath())
+	if scope == "" {
+		return errPATNotAllowed
+	}
+	if !t.HasScope(scope) {
+		return errInsufficientScope.WithMessage("令牌缺少 " + scope + " 权限")
+	}
+
+	user, err := s.Users.FindByID(ctx, t.UserID)
+	if errors.Is(err, ErrNotFound) {
+		return errTokenInvalid
+	}
+	if err != nil {
+		log.Errorf("查询用户失败: %v", err)
+		return errInternal
+	}
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= touchInterval {
+		if err := s.PersonalTokens.Touch(ctx, t.ID, now); err != nil {
+			log.Errorf("更新令牌使用时间失败: %v", err)
+		}
+	}
+
+	c.Set("userID", user.ID)
+	c.Set("username", user.Username)
+	c.Set("role", user.Role)
+	c.Set("tokenID", t.ID)
+	return nil
+}
+
+// CreatePersonalToken 创建个人访问令牌 POST /api/access-tokens 【需要登录，只接受JWT】
+// body：{"name":"备份脚本","scopes":["read","write:posts"],"expires_at":"RFC3339时间，可选"}
+func (s *Server) CreatePersonalToken(c *gin.Context) {
+	var req PersonalTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		fail(c, invalidField("expires_at", "gt", "必须晚于当前时间"))
+		return
+	}
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+
+	existing, err := s.PersonalTokens.ListByUser(ctx, userID)
+	if err != nil {
+		log.Errorf("查询个人访问令牌失败: %v", err)
+		fail(c, apperr.Internal("创建令牌失败"))
+		return
+	}
+	if len(existing) >= maxPersonalTokens {
+		fail(c, errTooManyAccessTokens)
+		return
+	}
+
+	// scope去重，按 Scopes 的顺序保存
+	var scopes []string
+	for _, scope := range Scopes {
+		for _, want := range req.Scopes {
+			if want == scope {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+	token := PATPrefix + randomString(32)
+	t := PersonalAccessToken{
+		UserID:    userID,
+		Name:      req.Name,
+		TokenHash: hashToken(token),
+		Hint:      token[:patHintLength],
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.PersonalTokens.Create(ctx, &t); err != nil {
+		log.Errorf("创建个人访问令牌失败: %v", err)
+		fail(c, apperr.Internal("创建令牌失败"))
+		return
+	}
+	log.Infof("用户ID:%d 创建个人访问令牌ID:%d，scope:%s", userID, t.ID, t.Scopes)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "创建成功，令牌只显示这一次，请妥善保存",
+		"data": PersonalTokenCreated{PersonalTokenView: personalTokenView(&t), Token: token}})
+}
+
+// ListPersonalTokens 我的个人访问令牌 GET /api/access-tokens 【需要登录，只接受JWT】
+// 只列出没有吊销的，包括已过期的，按创建时间倒序
+func (s *Server) ListPersonalTokens(c *gin.Context) {
+	tokens, err := s.PersonalTokens.ListByUser(c.Request.Context(), c.GetUint("userID"))
+	if err != nil {
+		log.Errorf("查询个人访问令牌失败: %v", err)
+		fail(c, apperr.Internal("获取令牌失败"))
+		return
+	}
+	views := make([]PersonalTokenView, len(tokens))
+	for i := range tokens {
+		views[i] = personalTokenView(&tokens[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "获取成功", "data": views})
+}
+
+// RevokePersonalToken 吊销个人访问令牌 DELETE /api/access-tokens/:id 【需要登录，只接受JWT】
+// 别人的令牌和已吊销的令牌都当作不存在
+func (s *Server) RevokePersonalToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, errInvalidAccessTokenID)
+		return
+	}
+	userID := c.GetUint("userID")
+	err = s.PersonalTokens.Revoke(c.Request.Context(), userID, uint(id), time.Now())
+	if errors.Is(err, ErrNotFound) {
+		fail(c, errAccessTokenNotFound)
+		return
+	}
+	if err != nil {
+		log.Errorf("吊销个人访问令牌失败: %v", err)
+		fail(c, apperr.Internal("吊销令牌失败"))
+		return
+	}
+	log.Infof("用户ID:%d 吊销个人访问令牌ID:%d", userID, id)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "令牌已吊销"})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	_, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	bob := sc.login("bob")
+	postID := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "Hello", "content": "hi"}, 200), "id")
+
+	// scope去重并按固定顺序保存，令牌以hint开头
+	created := sc.call("POST", "/api/access-tokens", bob, gin.H{"name": "ci", "scopes": []string{"write:comments", "read", "read"}}, 200)["data"].(map[string]interface{})
+	pat := created["token"].(string)
+	if scopes := created["scopes"].([]interface{}); len(scopes) != 2 || scopes[0] != ScopeRead || !strings.HasPrefix(pat, created["hint"].(string)) {
+		t.Errorf("创建的令牌不对: %v", created)
+	}
+	sc.call("POST", "/api/access-tokens", bob, gin.H{"name": "x", "scopes": []string{"read"}, "expires_at": "2000-01-01T00:00:00Z"}, 400)
+
+	// 按scope放行：有read和write:comments，没有write:posts
+	sc.call("GET", "/api/notifications", pat, nil, 200)
+	sc.call("GET", "/api/posts?status=draft", pat, nil, 200)
+	sc.call("POST", "/api/comments", pat, gin.H{"post_id": postID, "content": "via token"}, 200)
+	resp := sc.call("POST", "/api/posts", pat, gin.H{"title": "x", "content": "y"}, 403)
+	if code := errorCode(resp); code != errInsufficientScope.Code {
+		t.Errorf("缺少scope的错误码 %s，期望 %s", code, errInsufficientScope.Code)
+	}
+
+	// scope之外的接口、令牌管理和管理接口只接受JWT
+	for _, call := range []struct{ method, url string }{
+		{"POST", "/api/users/1/follow"},
+		{"GET", "/api/access-tokens"},
+		{"GET", "/api/admin/audit-logs"},
+		{"POST", "/api/logout"},
+	} {
+		resp := sc.call(call.method, call.url, pat, nil, 403)
+		if code := errorCode(resp); code != errPATNotAllowed.Code {
+			t.Errorf("%s %s 的错误码 %s，期望 %s", call.method, call.url, code, errPATNotAllowed.Code)
+		}
+	}
+
+	// 列表里有最近使用时间，没有令牌本身
+	tokens := sc.call("GET", "/api/access-tokens", bob, nil, 200)["data"].([]interface{})
+	if len(tokens) != 1 || tokens[0].(map[string]interface{})["last_used_at"] == nil || tokens[0].(map[string]interface{})["token"] != nil {
+		t.Fatalf("令牌列表不对: %v", tokens)
+	}
+
+	// 只能吊销自己的令牌，吊销后立即失效
+	patID := fmt.Sprint("/api/access-tokens/", created["id"])
+	sc.call("DELETE", patID, alice, nil, 404)
+	sc.call("DELETE", patID, bob, nil, 200)
+	sc.call("DELETE", patID, bob, nil, 404)
+	sc.call("GET", "/api/notifications", pat, nil, 401)
+	sc.call("GET", "/api/posts?status=draft", pat, nil, 400) // 公开接口上无效的令牌按游客处理
+	if tokens := sc.call("GET", "/api/access-tokens", bob, nil, 200)["data"].([]interface{}); len(tokens) != 0 {
+		t.Errorf("吊销后列表里还有令牌: %v", tokens)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ====================== 邮箱验证和找回密码 ======================
+// 注册后给邮箱发验证链接，忘记密码时发重置链接；链接里的令牌是 用途.用户ID.过期时间.随机串 加上HMAC签名，
+// 签名和过期时间不对的直接拒绝，不用查库；数据库里只存令牌的sha256，用一次就作废，重新申请时旧的也作废
+// 开启 accounts.require_verified_email 后，邮箱验证之前不能发文章和评论
+
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+// AccountToken 邮箱验证和重置密码的令牌表
+type AccountToken struct {
+	ID        uint       `gorm:"primarykey"`
+	UserID    uint       `gorm:"not null;index:idx_account_tokens_user"`
+	Purpose   string     `gorm:"not null;type:varchar(20);index:idx_account_tokens_user"` // verify_email/reset_password
+	TokenHash string     `gorm:"uniqueIndex;not null;type:varchar(64)"`                   // 令牌的sha256，数据库不存明文
+	Email     string     `gorm:"not null;type:varchar(100)"`                              // 发送时的邮箱，邮箱变了令牌就失效
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // 已使用或已作废的时间
+	CreatedAt time.Time
+}
+
+// EmailVerifyRequest 验证邮箱的请求体，token取自邮件里的链接
+type EmailVerifyRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest 申请重置密码的请求体
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email,max=100"`
+}
+
+// ResetPasswordRequest 重置密码的请求体
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6,max=72"` // bcrypt只取前72个字节
+}
+
+// accountTokenMAC 令牌的签名；密钥由JWT密钥派生，和JWT签名区分开
+func (s *Server) accountTokenMAC(payload string) string {
+	mac := hmac.New(sha256.New, []byte("account-token:"+s.cfg.JWT.Secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signAccountToken 生成带签名的令牌
+func (s *Server) signAccountToken(purpose string, userID uint, expiresAt time.Time) string {
+	raw := fmt.Sprintf("%s.%d.%d.%s", purpose, userID, expiresAt.Unix(), randomString(16))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(raw))
+	return payload + "." + s.accountTokenMAC(payload)
+}
+
+// verifyAccountToken 校验签名、用途和过期时间，返回令牌里的用户ID；还要查库确认没有用过
+func (s *Server) verifyAccountToken(token, purpose string, now time.Time) (uint, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.accountTokenMAC(payload))) {
+		return 0, errAccountToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return 0, errAccountToken
+	}
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 4 || parts[0] != purpose {
+		return 0, errAccountToken
+	}
+	userID, err1 := strconv.ParseUint(parts[1], 10, 32)
+	exp, err2 := strconv.ParseInt(parts[2], 10, 64)
+	if err1 != nil || err2 != nil || now.Unix() >= exp {
+		return 0, errAccountToken
+	}
+	return uint(userID), nil
+}
+
+// issueAccountToken 签发令牌并保存哈希，同一用途之前没用过的令牌全部作废
+func (s *Server) issueAccountToken(ctx context.Context, user *User, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	if err := s.AccountTokens.Invalidate(ctx, user.ID, purpose, now); err != nil {
+		return "", err
+	}
+	expiresAt := now.Add(ttl)
+	token := s.signAccountToken(purpose, user.ID, expiresAt)
+	record := AccountToken{UserID: user.ID, Purpose: purpose, TokenHash: hashToken(token), Email: user.Email, ExpiresAt: expiresAt}
+	if err := s.AccountTokens.Create(ctx, &record); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeAccountToken 校验令牌并标记为已使用，返回令牌对应的用户；令牌发出后邮箱改了也当作无效
+func (s *Server) consumeAccountToken(ctx context.Context, token, purpose string) (*User, error) {
+	now := time.Now()
+	userID, err := s.verifyAccountToken(token, purpose, now)
+	if err != nil {
+		return nil, err
+	}
+	record, err := s.AccountTokens.Consume(ctx, hashToken(token), purpose, now)
+	if errors.Is(err, ErrNotFound) {
+		return nil, errAccountToken
+	}
+	if err != nil {
+		log.Errorf("使用令牌失败: %v", err)
+		return nil, errInternal
+	}
+	user, err := s.Users.FindByID(ctx, userID)
+	if errors.Is(err, ErrNotFound) || (err == nil && (record.UserID != user.ID || record.Email != user.Email)) {
+		return nil, errAccountToken
+	}
+	if err != nil {
+		log.Errorf("查询用户失败: %v", err)
+		return nil, errInternal
+	}
+	return user, nil
+}
+
+// accountLink 邮件里的链接，打开的是前端页面，前端再调用对应的接口
+func (s *Server) accountLink(path, token string) string {
+	base := s.cfg.Accounts.LinkBaseURL
+	if base == "" {
+		base = s.cfg.Feed.SiteURL
+	}
+	return strings.TrimRight(base, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// formatTTL 邮件里的有效期，如 24小时、30分钟
+func formatTTL(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return strconv.Itoa(int(d/time.Hour)) + "小时"
+	}
+	return strconv.Itoa(int(d.Round(time.Minute)/time.Minute)) + "分钟"
+}
+
+// sendVerificationEmail 给用户的邮箱发验证链接
+func (s *Server) sendVerificationEmail(ctx context.Context, user *User) error {
+	ttl := s.cfg.Accounts.VerifyTTL.Duration
+	token, err := s.issueAccountToken(ctx, user, PurposeVerifyEmail, ttl)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, Mail{
+		To:      user.Email,
+		Subject: "验证你的邮箱",
+		Body: fmt.Sprintf("%s，你好：\n\n请打开下面的链接验证你的邮箱，%s内有效：\n%s\n\n如果你没有注册过，请忽略这封邮件。",
+			user.Username, formatTTL(ttl), s.accountLink("/verify-email", token)),
+	})
+}
+
+// sendPasswordResetEmail 给用户的邮箱发重置密码的链接
+func (s *Server) sendPasswordResetEmail(ctx context.Context, user *User) error {
+	ttl := s.cfg.Accounts.ResetTTL.Duration
+	token, err := s.issueAccountToken(ctx, user, PurposeResetPassword, ttl)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, Mail{
+		To:      user.Email,
+		Subject: "重置密码",
+		Body: fmt.Sprintf("%s，你好：\n\n请打开下面的链接设置新密码，%s内有效，只能使用一次：\n%s\n\n如果不是你本人申请的，请忽略这封邮件，你的密码不会改变。",
+			user.Username, formatTTL(ttl), s.accountLink("/reset-password", token)),
+	})
+}
+
+// RequireVerifiedEmail Gin中间件：开启 accounts.require_verified_email 时，邮箱没验证的用户不能继续，放在AuthMiddleware之后
+func (s *Server) RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.cfg.Accounts.RequireVerifiedEmail {
+			c.Next()
+			return
+		}
+		user, err := s.Users.FindByID(c.Request.Context(), c.GetUint("userID"))
+		if errors.Is(err, ErrNotFound) {
+			fail(c, errTokenInvalid)
+			return
+		}
+		if err != nil {
+			log.Errorf("查询用户失败: %v", err)
+			fail(c, errInternal)
+			return
+		}
+		if user.EmailVerifiedAt == nil {
+			fail(c, errEmailNotVerified)
+			return
+		}
+		c.Next()
+	}
+}
+
+// SendVerificationEmail 重新发送验证邮件 POST /api/email/verification 【需要登录】
+// 之前发的链接全部作废；已经验证过的直接返回成功
+func (s *Server) SendVerificationEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, err := s.Users.FindByID(ctx, c.GetUint("userID"))
+	if err != nil {
+		log.Errorf("查询用户失败: %v", err)
+		fail(c, apperr.Internal("发送验证邮件失败"))
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "邮箱已经验证过了"})
+		return
+	}
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Errorf("发送验证邮件失败: %v", err)
+		fail(c, apperr.Internal("发送验证邮件失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "验证邮件已发送，请查收"})
+}
+
+// VerifyEmail 验证邮箱 POST /api/email/verify 【无需登录】
+// body：{"token":"邮件链接里的token"}
+func (s *Server) VerifyEmail(c *gin.Context) {
+	var req EmailVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := s.consumeAccountToken(ctx, req.Token, PurposeVerifyEmail)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	if err := s.Users.MarkEmailVerified(ctx, user.ID, time.Now()); err != nil {
+		log.Errorf("保存邮箱验证状态失败: %v", err)
+		fail(c, apperr.Internal("验证邮箱失败"))
+		return
+	}
+	log.Infof("用户ID:%d 验证邮箱成功", user.ID)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "邮箱验证成功！"})
+}
+
+// ForgotPassword 申请重置密码 POST /api/password/forgot 【无需登录】
+// 不管邮箱有没有注册都返回同样的结果，防止被用来探测哪些邮箱注册过
+func (s *Server) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := s.Users.FindByEmail(ctx, req.Email)
+	switch {
+	case err == nil:
+		if err := s.sendPasswordResetEmail(ctx, user); err != nil {
+			log.Errorf("发送重置密码邮件失败: %v", err)
+		}
+	case !errors.Is(err, ErrNotFound):
+		log.Errorf("查询用户失败: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "如果该邮箱已注册，重置密码的邮件已发送，请查收"})
+}
+
+// ResetPassword 重置密码 POST /api/password/reset 【无需登录】
+// 成功后所有已登录的设备都要重新登录，个人访问令牌全部吊销；能收到邮件说明邮箱是本人的，顺便标记为已验证
+func (s *Server) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := s.consumeAccountToken(ctx, req.Token, PurposeResetPassword)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Errorf("密码加密失败: %v", err)
+		fail(c, apperr.Internal("重置密码失败"))
+		return
+	}
+	if err := s.Users.UpdatePassword(ctx, user.ID, string(hashedPwd)); err != nil {
+		log.Errorf("保存新密码失败: %v", err)
+		fail(c, apperr.Internal("重置密码失败"))
+		return
+	}
+	if err := s.Tokens.RevokeUserTokens(ctx, user.ID, s.cfg.JWT.AccessTTL.Duration); err != nil {
+		log.Errorf("重置密码后吊销令牌失败: %v", err)
+		fail(c, apperr.Internal("重置密码失败"))
+		return
+	}
+	// 个人访问令牌也一起吊销，否则盗用账号的人拿着令牌还能继续写文章
+	if err := s.PersonalTokens.RevokeAll(ctx, user.ID, time.Now()); err != nil {
+		log.Errorf("重置密码后吊销个人访问令牌失败: %v", err)
+		fail(c, apperr.Internal("重置密码失败"))
+		return
+	}
+	if err := s.Users.MarkEmailVerified(ctx, user.ID, time.Now()); err != nil {
+		log.Errorf("保存邮箱验证状态失败: %v", err)
+	}
+	log.Infof("用户ID:%d 重置密码成功", user.ID)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "密码已重置，请重新登录"})
+}
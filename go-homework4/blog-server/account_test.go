@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEmailVerification(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	post := sc.call("POST", "/api/posts", sc.login("bob"), gin.H{"title": "Hello", "content": "hi"}, 200)
+	dave := sc.login("dave")
+	first := mailToken(t, s, "/verify-email") // 注册时发的验证邮件
+
+	// 打开 require_verified_email 后，邮箱没验证不能发评论
+	s.cfg.Accounts.RequireVerifiedEmail = true
+	comment := gin.H{"post_id": sc.id(post, "id"), "content": "hi"}
+	resp := sc.call("POST", "/api/comments", dave, comment, 403)
+	if code := errorCode(resp); code != errEmailNotVerified.Code {
+		t.Errorf("错误码 %s，期望 %s", code, errEmailNotVerified.Code)
+	}
+
+	// 重新发送后，注册时发的链接作废；令牌只能用一次，签名不对也不行
+	sc.call("POST", "/api/email/verification", dave, nil, 200)
+	verify := mailToken(t, s, "/verify-email")
+	sc.call("POST", "/api/email/verify", "", gin.H{"token": first}, 400)
+	sc.call("POST", "/api/email/verify", "", gin.H{"token": verify + "x"}, 400)
+	sc.call("POST", "/api/email/verify", "", gin.H{"token": verify}, 200)
+	sc.call("POST", "/api/email/verify", "", gin.H{"token": verify}, 400)
+
+	sc.call("POST", "/api/comments", dave, comment, 200)
+	resp = sc.call("POST", "/api/email/verification", dave, nil, 200)
+	if resp["msg"] != "邮箱已经验证过了" {
+		t.Errorf("验证过之后再申请: %v", resp)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	dave := sc.login("dave")
+	pat := sc.call("POST", "/api/access-tokens", dave, gin.H{"name": "ci", "scopes": []string{"read"}}, 200)["data"].(map[string]interface{})["token"].(string)
+	sc.call("GET", "/api/notifications", pat, nil, 200)
+
+	// 没注册的邮箱也返回成功，不泄露邮箱是否注册过
+	sc.call("POST", "/api/password/forgot", "", gin.H{"email": "nobody@example.com"}, 200)
+	sc.call("POST", "/api/password/forgot", "", gin.H{"email": "dave@example.com"}, 200)
+	reset := mailToken(t, s, "/reset-password")
+	verify := mailToken(t, s, "/verify-email")
+
+	sc.call("POST", "/api/password/reset", "", gin.H{"token": verify, "password": "newsecret"}, 400) // 用途不对
+	sc.call("POST", "/api/password/reset", "", gin.H{"token": reset, "password": "newsecret"}, 200)
+	sc.call("POST", "/api/password/reset", "", gin.H{"token": reset, "password": "another"}, 400)
+
+	// 重置密码后旧的登录和个人访问令牌全部失效，只能用新密码登录
+	sc.call("POST", "/api/logout", dave, nil, 401)
+	sc.call("GET", "/api/notifications", pat, nil, 401)
+	sc.call("POST", "/api/login", "", gin.H{"username": "dave", "password": "secret"}, 401)
+	sc.call("POST", "/api/login", "", gin.H{"username": "dave", "password": "newsecret"}, 200)
+}
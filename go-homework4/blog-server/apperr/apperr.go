@@ -0,0 +1,136 @@
+// Package apperr 应用错误：每个错误带一个类别（决定HTTP状态码）、一个稳定的错误码和给用户看的中文信息
+// 错误码是接口契约的一部分，前端按错误码判断是什么错误，信息文字可以随时调整；已经发布的错误码不要改名
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind 错误类别，决定HTTP状态码
+type Kind string
+
+const (
+	KindValidation   Kind = "validation"   // 400 参数不合法
+	KindUnauthorized Kind = "unauthorized" // 401 没有登录或令牌无效
+	KindForbidden    Kind = "forbidden"    // 403 没有权限
+	KindNotFound     Kind = "not_found"    // 404 资源不存在
+	KindConflict     Kind = "conflict"     // 409 和已有数据冲突，如用户名已存在
+	KindRateLimited  Kind = "rate_limited" // 429 请求太频繁
+	KindInternal     Kind = "internal"     // 500 服务器内部错误，信息里不带任何内部细节
+	KindUnavailable  Kind = "unavailable"  // 503 服务或依赖暂时不可用
+)
+
+var statusByKind = map[Kind]int{
+	KindValidation:   http.StatusBadRequest,
+	KindUnauthorized: http.StatusUnauthorized,
+	KindForbidden:    http.StatusForbidden,
+	KindNotFound:     http.StatusNotFound,
+	KindConflict:     http.StatusConflict,
+	KindRateLimited:  http.StatusTooManyRequests,
+	KindInternal:     http.StatusInternalServerError,
+	KindUnavailable:  http.StatusServiceUnavailable,
+}
+
+// FieldError 某个字段没通过校验
+type FieldError struct {
+	Field   string `json:"field"`          // 字段名，和请求里的JSON字段或查询参数同名
+	Rule    string `json:"rule,omitempty"` // 没通过的规则，如 required、max、oneof
+	Message string `json:"message"`
+}
+
+// Error 应用错误；接口里定义成包级变量复用时，With开头的方法都返回副本，不会改到原来的值
+type Error struct {
+	Kind    Kind
+	Code    string       // 稳定的错误码，如 post.not_found
+	Message string       // 给用户看的信息
+	Fields  []FieldError // 字段级的校验错误，一般只有validation类别有
+	status  int          // 不为0时代替类别对应的状态码，如上传接口的413、415
+}
+
+// New 创建错误
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func Validation(code, message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message, Fields: fields}
+}
+
+func Unauthorized(code, message string) *Error {
+	return New(KindUnauthorized, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func NotFound(code, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+func RateLimited(code, message string) *Error {
+	return New(KindRateLimited, code, message)
+}
+
+// Internal 服务器内部错误，错误码统一为 internal；原始错误由调用方记日志，不要放进message
+func Internal(message string) *Error {
+	return New(KindInternal, "internal", message)
+}
+
+func Unavailable(code, message string) *Error {
+	return New(KindUnavailable, code, message)
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Status 对应的HTTP状态码
+func (e *Error) Status() int {
+	if e.status != 0 {
+		return e.status
+	}
+	if status, ok := statusByKind[e.Kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// WithStatus 返回改了HTTP状态码的副本，用于类别对应的状态码不够准确的场景
+func (e *Error) WithStatus(status int) *Error {
+	cp := *e
+	cp.status = status
+	return &cp
+}
+
+// WithMessage 返回改了信息的副本，用于信息里要带上具体值的场景
+func (e *Error) WithMessage(message string) *Error {
+	cp := *e
+	cp.Message = message
+	return &cp
+}
+
+// WithField 返回多了一个字段错误的副本
+func (e *Error) WithField(field, rule, message string) *Error {
+	cp := *e
+	cp.Fields = append(append([]FieldError(nil), e.Fields...), FieldError{Field: field, Rule: rule, Message: message})
+	return &cp
+}
+
+// Is 错误码相同就认为是同一个错误，errors.Is(err, errPostNotFound) 对副本也成立
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Kind == e.Kind
+}
+
+// As 从错误链里取出应用错误
+func As(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"time"
+)
+
+// ====================== 楼中楼评论：回复树 + 墓碑 ======================
+// 评论通过ParentID回复另一条评论，可以无限嵌套，嵌套层数由配置 comments.max_depth 限制
+// 删除评论是软删除：没有回复的直接隐藏，还有回复的渲染成墓碑（内容和作者清空），保证下面的回复不丢
+
+// CommentNode 评论树的一个节点
+type CommentNode struct {
+	ID        uint            `json:"id"`
+	PostID    uint            `json:"post_id"`
+	ParentID  *uint           `json:"parent_id"`
+	Depth     int             `json:"depth"` // 顶层评论为0
+	Content   string          `json:"content"`
+	Author    *UserView       `json:"author"`  // 墓碑为null
+	Deleted   bool            `json:"deleted"` // true表示墓碑
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Reactions []ReactionCount `json:"reactions"`         // 表态统计，见 reaction.go；墓碑为空
+	Replies   []*CommentNode  `json:"replies,omitempty"` // 平铺模式下不返回
+}
+
+// commentNode 一条评论转成不带回复的节点；已删除的评论是墓碑，内容和作者清空
+// 发表和修改评论的接口也用它返回评论
+func commentNode(cm *Comment) *CommentNode {
+	node := &CommentNode{
+		ID:        cm.ID,
+		PostID:    cm.PostID,
+		ParentID:  cm.ParentID,
+		Depth:     cm.Depth,
+		CreatedAt: cm.CreatedAt,
+		UpdatedAt: cm.UpdatedAt,
+		Reactions: []ReactionCount{},
+	}
+	if cm.DeletedAt.Valid {
+		node.Deleted = true
+	} else {
+		node.Content = cm.Content
+		node.Author = &UserView{ID: cm.UserID, Username: cm.User.Username}
+	}
+	return node
+}
+
+// buildCommentTree 把一篇文章的全部评论（包含已删除的）组装成回复树
+// 结果按创建顺序排列；已删除且没有存活回复的评论整棵剪掉
+func buildCommentTree(comments []Comment) []*CommentNode {
+	nodes := make(map[uint]*CommentNode, len(comments))
+	for i := range comments {
+		node := commentNode(&comments[i])
+		node.Replies = []*CommentNode{}
+		nodes[node.ID] = node
+	}
+
+	roots := []*CommentNode{}
+	for i := range comments {
+		node := nodes[comments[i].ID]
+		var parent *CommentNode
+		if node.ParentID != nil {
+			parent = nodes[*node.ParentID]
+		}
+		if parent != nil {
+			parent.Replies = append(parent.Replies, node)
+		} else {
+			// 顶层评论，或者父评论的数据已经不存在（按顶层处理）
+			roots = append(roots, node)
+		}
+	}
+	return pruneTombstones(roots)
+}
+
+// pruneTombstones 剪掉没有存活回复的墓碑
+func pruneTombstones(nodes []*CommentNode) []*CommentNode {
+	kept := nodes[:0]
+	for _, n := range nodes {
+		n.Replies = pruneTombstones(n.Replies)
+		if n.Deleted && len(n.Replies) == 0 {
+			continue
+		}
+		kept = append(kept, n)
+	}
+	return kept
+}
+
+// flattenCommentTree 按深度优先顺序把回复树平铺成列表，靠depth字段还原层级
+func flattenCommentTree(nodes []*CommentNode) []*CommentNode {
+	flat := []*CommentNode{}
+	var walk func([]*CommentNode)
+	walk = func(nodes []*CommentNode) {
+		for _, n := range nodes {
+			replies := n.Replies
+			n.Replies = nil
+			flat = append(flat, n)
+			walk(replies)
+		}
+	}
+	walk(nodes)
+	return flat
+}
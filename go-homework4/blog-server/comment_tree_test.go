@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestBuildCommentTree(t *testing.T) {
+	now := time.Now()
+	id := func(v uint) *uint { return &v }
+	comment := func(cid uint, parent *uint, depth int, deleted bool) Comment {
+		cm := Comment{Content: "内容", UserID: 1, User: User{Username: "alice"}, PostID: 1, ParentID: parent, Depth: depth}
+		cm.ID = cid
+		if deleted {
+			cm.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		}
+		return cm
+	}
+	comments := []Comment{
+		comment(1, nil, 0, true), // 已删除，还有存活的回复：保留为墓碑
+		comment(2, id(1), 1, false),
+		comment(3, id(2), 2, true), // 已删除的叶子：剪掉
+		comment(4, nil, 0, false),
+		comment(5, nil, 0, true), // 已删除的顶层评论，没有回复：剪掉
+		comment(6, nil, 0, true), // 已删除，回复也都删了：整棵剪掉
+		comment(7, id(6), 1, true),
+		comment(8, id(99), 1, false), // 父评论的数据不存在，按顶层处理
+	}
+	tree := buildCommentTree(comments)
+
+	// 用 "ID(子节点...)" 描述树的形状，墓碑加*
+	var shape func([]*CommentNode) []string
+	shape = func(nodes []*CommentNode) []string {
+		out := []string{}
+		for _, n := range nodes {
+			s := strconv.FormatUint(uint64(n.ID), 10)
+			if n.Deleted {
+				s += "*"
+			}
+			for _, r := range shape(n.Replies) {
+				s += "(" + r + ")"
+			}
+			out = append(out, s)
+		}
+		return out
+	}
+	if got, want := shape(tree), []string{"1*(2)", "4", "8"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("评论树是 %v，期望 %v", got, want)
+	}
+
+	tomb := tree[0]
+	if tomb.Author != nil || tomb.Content != "" || !tomb.Deleted {
+		t.Errorf("墓碑的作者和内容应该清空: %+v", tomb)
+	}
+	if live := tomb.Replies[0]; live.Author == nil || live.Author.Username != "alice" || live.Content != "内容" || live.Deleted {
+		t.Errorf("存活的回复不对: %+v", live)
+	}
+
+	// 平铺后按深度优先排列，depth还原层级
+	var flat []uint
+	var depths []int
+	for _, n := range flattenCommentTree(tree) {
+		if n.Replies != nil {
+			t.Errorf("平铺后评论 %d 还带着回复", n.ID)
+		}
+		flat = append(flat, n.ID)
+		depths = append(depths, n.Depth)
+	}
+	if !reflect.DeepEqual(flat, []uint{1, 2, 4, 8}) || !reflect.DeepEqual(depths, []int{0, 1, 0, 1}) {
+		t.Errorf("平铺结果 %v 层级 %v", flat, depths)
+	}
+}
+
+func TestCommentMaxDepth(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	s.cfg.Comments.MaxDepth = 1
+	postID := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "标题", "content": "内容"}, 200), "id")
+
+	root := sc.id(sc.call("POST", "/api/comments", alice, gin.H{"post_id": postID, "content": "顶层"}, 200), "id")
+	reply := sc.id(sc.call("POST", "/api/comments", alice, gin.H{"post_id": postID, "parent_id": root, "content": "第1层"}, 200), "id")
+	resp := sc.call("POST", "/api/comments", alice, gin.H{"post_id": postID, "parent_id": reply, "content": "第2层"}, 400)
+	if code := errorCode(resp); code != errCommentTooDeep.Code {
+		t.Errorf("错误码 %s，期望 %s", code, errCommentTooDeep.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"time"
+)
+
+// ====================== 请求和响应的数据结构 ======================
+// 接口不直接绑定或返回数据库模型：请求体绑定到下面的请求结构体，校验规则写在binding tag里；
+// 响应只返回视图结构体，作者等关联用户只带ID和用户名，密码哈希、邮箱这些字段不会出现在任何响应里
+
+// RegisterRequest 注册的请求体
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required,max=50"`
+	Password string `json:"password" binding:"required,min=6,max=72"` // bcrypt只取前72个字节
+	Email    string `json:"email" binding:"required,email,max=100"`
+}
+
+// LoginRequest 登录的请求体
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// CreateCommentRequest 发表评论的请求体，回复评论时带上parent_id
+type CreateCommentRequest struct {
+	PostID   uint   `json:"post_id" binding:"required"`
+	ParentID *uint  `json:"parent_id"`
+	Content  string `json:"content" binding:"required"`
+}
+
+// UserView 响应里的用户信息，只有ID和用户名
+type UserView struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+// PostView 响应里的文章
+type PostView struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Format      string     `json:"format"`
+	Status      string     `json:"status"`
+	Author      UserView   `json:"author"`
+	Category    *Category  `json:"category"` // 没有分类时为null
+	Tags        []Tag      `json:"tags"`
+	PublishAt   *time.Time `json:"publish_at"`   // 定时发布时间，只有scheduled状态有值
+	PublishedAt *time.Time `json:"published_at"` // 第一次发布的时间
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Reactions []ReactionCount `json:"reactions"` // 表态统计，见 reaction.go
+}
+
+// postView 文章转成响应结构；作者没有关联查询出来时只有ID
+func postView(p *Post) PostView {
+	tags := p.Tags
+	if tags == nil {
+		tags = []Tag{}
+	}
+	return PostView{
+		ID:          p.ID,
+		Title:       p.Title,
+		Content:     p.Content,
+		Format:      p.Format,
+		Status:      p.Status,
+		Author:      UserView{ID: p.UserID, Username: p.User.Username},
+		Category:    p.Category,
+		Tags:        tags,
+		PublishAt:   p.PublishAt,
+		PublishedAt: p.PublishedAt,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+		Reactions:   []ReactionCount{},
+	}
+}
+
+func postViews(posts []Post) []PostView {
+	views := make([]PostView, len(posts))
+	for i := range posts {
+		views[i] = postView(&posts[i])
+	}
+	return views
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ====================== 错误处理：统一的错误响应 ======================
+// handler和中间件出错时调用 fail(c, err) 后直接return，由ErrorMiddleware统一输出：
+//   {"code": 404, "msg": "文章不存在", "error": {"kind": "not_found", "code": "post.not_found"}}
+// code和msg和以前的响应兼容；前端判断是什么错误要用 error.code，msg只用来展示，文字随时可能调整
+// 参数校验失败时 error.fields 里列出每个字段的问题；500错误的msg不带任何内部细节，原始错误只记日志
+
+// ErrorResponse 错误响应的格式
+type ErrorResponse struct {
+	Code  int         `json:"code"` // HTTP状态码
+	Msg   string      `json:"msg"`
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail 错误的类别、稳定的错误码和字段级的校验错误
+type ErrorDetail struct {
+	Kind   apperr.Kind         `json:"kind"`
+	Code   string              `json:"code"`
+	Fields []apperr.FieldError `json:"fields,omitempty"`
+}
+
+// 接口里用到的错误，错误码一经发布不要修改
+var (
+	// ---------------------- 通用 ----------------------
+	errInternal      = apperr.Internal("服务器内部错误")
+	errNotFound      = apperr.NotFound("not_found", "资源不存在")
+	errConflict      = apperr.Conflict("conflict", "记录已存在")
+	errRouteNotFound = apperr.NotFound("route.not_found", "接口不存在")
+	errMalformedBody = apperr.Validation("request.malformed", "参数错误：请求体必须是一个JSON对象")
+	errRateLimited   = apperr.RateLimited("rate_limited", "请求太频繁，请稍后再试")
+	errNotReady      = apperr.Unavailable("service.not_ready", "服务未就绪")
+	errShuttingDown  = apperr.Unavailable("service.shutting_down", "服务正在关闭")
+
+	// ---------------------- 用户和认证 ----------------------
+	errUsernameTaken     = apperr.Conflict("user.username_taken", "注册失败，用户名已存在").WithField("username", "unique", "已被注册")
+	errEmailTaken        = apperr.Conflict("user.email_taken", "注册失败，邮箱已存在").WithField("email", "unique", "已被注册")
+	errUserExists        = apperr.Conflict("user.exists", "注册失败，用户名/邮箱已存在")
+	errBadCredentials    = apperr.Unauthorized("auth.invalid_credentials", "用户名或密码错误")
+	errTokenMissing      = apperr.Unauthorized("auth.token_missing", "未携带token，请先登录")
+	errTokenInvalid      = apperr.Unauthorized("auth.token_invalid", "token无效或已过期")
+	errTokenRevoked      = apperr.Unauthorized("auth.token_revoked", "token已失效，请重新登录")
+	errRefreshInvalid    = apperr.Unauthorized("auth.refresh_token_invalid", "refresh token无效或已过期")
+	errRefreshRevoked    = apperr.Unauthorized("auth.refresh_token_revoked", "refresh token已失效，请重新登录")
+	errPermissionDenied  = apperr.Forbidden("auth.permission_denied", "权限不足")
+	errInvalidUserID     = apperr.Validation("user.invalid_id", "用户ID格式错误")
+	errUserNotFound      = apperr.NotFound("user.not_found", "用户不存在")
+	errChangeOwnRole     = apperr.Validation("user.change_own_role", "不能修改自己的角色")
+	errInvalidRole       = invalidField("role", "oneof", "只支持 user、moderator 或 admin")
+	errInvalidFeedFormat = invalidField("format", "oneof", "只支持 rss 或 atom")
+	errFollowSelf        = apperr.Validation("follow.self", "不能关注自己")
+	errEmailNotVerified  = apperr.Forbidden("auth.email_not_verified", "请先验证邮箱")
+	errPATNotAllowed     = apperr.Forbidden("auth.token_not_allowed", "该接口不接受个人访问令牌，请使用登录获得的token")
+	errInsufficientScope = apperr.Forbidden("auth.insufficient_scope", "令牌的权限不足")
+	errAccountToken      = apperr.Validation("account.token_invalid", "链接无效或已过期，请重新获取")
+
+	// ---------------------- 个人访问令牌 ----------------------
+	errInvalidAccessTokenID = apperr.Validation("access_token.invalid_id", "令牌ID格式错误")
+	errAccessTokenNotFound  = apperr.NotFound("access_token.not_found", "令牌不存在")
+	errTooManyAccessTokens  = apperr.Validation("access_token.limit", "令牌数量已达上限，请先吊销不用的令牌")
+
+	// ---------------------- 文章 ----------------------
+	errInvalidPostID     = apperr.Validation("post.invalid_id", "文章ID格式错误")
+	errPostNotFound      = apperr.NotFound("post.not_found", "文章不存在")
+	errPostForbidden     = apperr.Forbidden("post.forbidden", "无权修改该文章，你不是作者")
+	errInvalidFormat     = invalidField("format", "oneof", "只支持 markdown、plain 或 html")
+	errInvalidRevID      = apperr.Validation("revision.invalid_id", "版本号格式错误")
+	errRevNotFound       = apperr.NotFound("revision.not_found", "版本不存在")
+	errInvalidSearchType = invalidField("type", "oneof", "只支持 all、post 或 comment")
+
+	// ---------------------- 评论 ----------------------
+	errInvalidCommentID  = apperr.Validation("comment.invalid_id", "评论ID格式错误")
+	errCommentNotFound   = apperr.NotFound("comment.not_found", "评论不存在")
+	errCommentForbidden  = apperr.Forbidden("comment.forbidden", "无权修改该评论，你不是作者")
+	errCommentPostGone   = apperr.NotFound("comment.post_not_found", "评论的文章不存在")
+	errParentNotFound    = apperr.NotFound("comment.parent_not_found", "回复的评论不存在")
+	errParentOtherPost   = apperr.Validation("comment.parent_mismatch", "回复的评论不属于该文章")
+	errCommentTooDeep    = apperr.Validation("comment.too_deep", "回复层数超过上限")
+	errInvalidFlatOption = invalidField("flat", "boolean", "只支持 true 或 false")
+
+	// ---------------------- 通知 ----------------------
+	errInvalidNotificationID = apperr.Validation("notification.invalid_id", "通知ID格式错误")
+	errNotificationNotFound  = apperr.NotFound("notification.not_found", "通知不存在")
+
+	// ---------------------- 上传和附件 ----------------------
+	errStorageDisabled     = apperr.Unavailable("upload.storage_disabled", "未配置文件存储")
+	errFileTooLarge        = apperr.Validation("upload.too_large", "文件太大").WithStatus(413)
+	errFileMissing         = invalidField("file", "required", "请用 file 字段上传文件")
+	errUnsupportedType     = apperr.Validation("upload.unsupported_type", "不支持的文件类型").WithStatus(415)
+	errInvalidImage        = apperr.Validation("upload.invalid_image", "图片无法解析")
+	errUploadForbidden     = apperr.Forbidden("upload.forbidden", "只能给自己的文章上传附件")
+	errAttachmentOtherPost = apperr.Conflict("upload.attached_elsewhere", "同样的文件已经是你另一篇文章的附件")
+	errInvalidAttachmentID = apperr.Validation("attachment.invalid_id", "附件ID格式错误")
+	errAttachmentNotFound  = apperr.NotFound("attachment.not_found", "附件不存在")
+	errAttachmentForbidden = apperr.Forbidden("attachment.forbidden", "无权删除该附件，你不是上传者")
+)
+
+// invalidField 某个参数不合法，信息沿用以前"参数错误：字段 说明"的写法
+func invalidField(field, rule, message string) *apperr.Error {
+	return apperr.Validation("request.invalid", "参数错误："+field+" "+message).WithField(field, rule, message)
+}
+
+// fail 记下错误并终止后面的处理函数，响应由ErrorMiddleware统一输出；调用后直接return
+func fail(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// ErrorMiddleware 把 fail 记下的最后一个错误输出成统一的错误响应
+// 要放在MetricsMiddleware之后，监控才能统计到错误响应的状态码
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		e := toAppError(c, c.Errors.Last().Err)
+		c.JSON(e.Status(), ErrorResponse{
+			Code:  e.Status(),
+			Msg:   e.Message,
+			Error: ErrorDetail{Kind: e.Kind, Code: e.Code, Fields: e.Fields},
+		})
+	}
+}
+
+// toAppError 没有包装成应用错误的仓储错误按通用的404、409处理，其它错误一律按500处理
+func toAppError(c *gin.Context, err error) *apperr.Error {
+	if e, ok := apperr.As(err); ok {
+		return e
+	}
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return errNotFound
+	case errors.Is(err, ErrDuplicate):
+		return errConflict
+	}
+	log.Errorf("%s %s 未处理的错误: %v", c.Request.Method, c.Request.URL.Path, err)
+	return errInternal
+}
+
+// NotFoundHandler 没有匹配到路由时也返回统一的错误格式
+func NotFoundHandler(c *gin.Context) {
+	fail(c, errRouteNotFound)
+}
+
+// ---------------------- 参数绑定错误 ----------------------
+
+// 校验错误里的字段名用JSON字段名或查询参数名，和请求里的写法一致
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(requestFieldName)
+	}
+}
+
+func requestFieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// bindError 把ShouldBind系列函数的错误转换成400，校验错误逐个字段列出
+func bindError(err error) *apperr.Error {
+	var fieldErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &fieldErrs):
+		e := apperr.Validation("request.invalid", "")
+		msgs := make([]string, 0, len(fieldErrs))
+		for _, fe := range fieldErrs {
+			msg := fieldErrorMessage(fe)
+			e = e.WithField(fe.Field(), fe.Tag(), msg)
+			msgs = append(msgs, fe.Field()+" "+msg)
+		}
+		return e.WithMessage("参数错误：" + strings.Join(msgs, "；"))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return invalidField(typeErr.Field, "type", "类型应该是 "+typeErr.Type.String())
+	case errors.As(err, &typeErr), errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return errMalformedBody
+	}
+	return apperr.Validation("request.invalid", "参数错误："+err.Error())
+}
+
+// fieldErrorMessage 常用校验规则的中文说明
+func fieldErrorMessage(fe validator.FieldError) string {
+	unit := ""
+	switch fe.Kind() {
+	case reflect.String:
+		unit = "个字符"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = "项"
+	}
+	switch fe.Tag() {
+	case "required":
+		return "不能为空"
+	case "email":
+		return "不是合法的邮箱地址"
+	case "oneof":
+		return "只支持 " + strings.ReplaceAll(fe.Param(), " ", "、")
+	case "min", "gte":
+		if unit != "" {
+			return "不能少于" + fe.Param() + unit
+		}
+		return "不能小于" + fe.Param()
+	case "max", "lte":
+		if unit != "" {
+			return "不能超过" + fe.Param() + unit
+		}
+		return "不能大于" + fe.Param()
+	case "len":
+		return "必须是" + fe.Param() + unit
+	}
+	return "不符合 " + fe.Tag() + " 规则"
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 订阅：RSS 2.0 / Atom ======================
+// 全站订阅 /feed.xml、/atom.xml，单个作者 /api/users/:id/feed；只包含已发布的文章，按创建时间倒序
+// 文章的唯一标识用tag URI（RFC 4151），只和站点域名、文章ID、创建日期有关，改标题改内容都不会变
+// 支持条件请求：响应带ETag和Last-Modified，内容没变时返回304
+
+const (
+	FeedRSS  = "rss"
+	FeedAtom = "atom"
+)
+
+// ---------------------- RSS 2.0 ----------------------
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"` // 渲染后的HTML，由xml编码负责转义
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator,omitempty"`
+	Categories  []string `xml:"category"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// ---------------------- Atom ----------------------
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// ---------------------- 接口 ----------------------
+
+// feedSource 一个订阅的元信息和文章
+type feedSource struct {
+	title       string
+	description string
+	link        string // 订阅对应的页面地址
+	self        string // 订阅自身的地址
+	id          string // Atom的feed id，用一个固定的地址
+	posts       []Post
+}
+
+// RSSFeed 全站RSS订阅 GET /feed.xml 【无需登录】
+func (s *Server) RSSFeed(c *gin.Context) {
+	s.serveFeed(c, FeedRSS, 0)
+}
+
+// AtomFeed 全站Atom订阅 GET /atom.xml 【无需登录】
+func (s *Server) AtomFeed(c *gin.Context) {
+	s.serveFeed(c, FeedAtom, 0)
+}
+
+// UserFeed 单个作者的订阅 GET /api/users/:id/feed?format=rss 【无需登录】
+// format：rss(默认) / atom
+func (s *Server) UserFeed(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, errInvalidUserID)
+		return
+	}
+	format := c.DefaultQuery("format", FeedRSS)
+	if format != FeedRSS && format != FeedAtom {
+		fail(c, errInvalidFeedFormat)
+		return
+	}
+	s.serveFeed(c, format, uint(id))
+}
+
+// serveFeed 生成订阅并按条件请求返回；authorID为0表示全站
+func (s *Server) serveFeed(c *gin.Context, format string, authorID uint) {
+	src, err := s.loadFeed(c.Request.Context(), format, authorID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			fail(c, errUserNotFound)
+			return
+		}
+		log.Errorf("生成订阅失败: %v", err)
+		fail(c, apperr.Internal("生成订阅失败"))
+		return
+	}
+
+	var lastModified time.Time
+	for _, p := range src.posts {
+		if p.UpdatedAt.After(lastModified) {
+			lastModified = p.UpdatedAt
+		}
+	}
+
+	var doc interface{}
+	contentType := "application/rss+xml; charset=utf-8"
+	if format == FeedAtom {
+		doc = s.atomDocument(src, lastModified)
+		contentType = "application/atom+xml; charset=utf-8"
+	} else {
+		doc = s.rssDocument(src, lastModified)
+	}
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Errorf("生成订阅失败: %v", err)
+		fail(c, apperr.Internal("生成订阅失败"))
+		return
+	}
+	writeConditional(c, contentType, append([]byte(xml.Header), body...), lastModified)
+}
+
+// loadFeed 查询订阅的文章，条数由配置 feed.limit 决定
+func (s *Server) loadFeed(ctx context.Context, format string, authorID uint) (*feedSource, error) {
+	cfg := s.cfg.Feed
+	site := strings.TrimRight(cfg.SiteURL, "/")
+	src := &feedSource{
+		title:       cfg.Title,
+		description: cfg.Description,
+		link:        site + "/",
+		id:          site + "/atom.xml",
+	}
+	if format == FeedAtom {
+		src.self = site + "/atom.xml"
+	} else {
+		src.self = site + "/feed.xml"
+	}
+
+	q := PostQuery{AuthorID: authorID, PageSize: cfg.Limit}
+	if authorID != 0 {
+		user, err := s.Users.FindByID(ctx, authorID)
+		if err != nil {
+			return nil, err
+		}
+		src.title = cfg.Title + " - " + user.Username
+		src.description = user.Username + " 发布的文章"
+		src.link = site + "/api/posts?author_id=" + strconv.FormatUint(uint64(authorID), 10)
+		src.self = site + "/api/users/" + strconv.FormatUint(uint64(authorID), 10) + "/feed?format=" + format
+		src.id = site + "/api/users/" + strconv.FormatUint(uint64(authorID), 10) + "/feed"
+	}
+	if err := q.Normalize(); err != nil {
+		return nil, err
+	}
+	posts, total, err := s.Posts.List(ctx, &q)
+	if err != nil {
+		return nil, err
+	}
+	src.posts, _ = q.Paginate(posts, total) // List多取了一条，截掉
+	return src, nil
+}
+
+func (s *Server) rssDocument(src *feedSource, lastModified time.Time) *rssFeed {
+	feed := &rssFeed{Version: "2.0", Channel: rssChannel{
+		Title:       src.title,
+		Link:        src.link,
+		Description: src.description,
+		Items:       []rssItem{},
+	}}
+	if !lastModified.IsZero() {
+		feed.Channel.LastBuildDate = lastModified.UTC().Format(time.RFC1123Z)
+	}
+	for i := range src.posts {
+		p := &src.posts[i]
+		item := rssItem{
+			Title:       p.Title,
+			Link:        s.postURL(p.ID),
+			Description: feedContent(p),
+			Creator:     p.User.Username,
+			Categories:  postTerms(p),
+			GUID:        rssGUID{IsPermaLink: false, Value: s.postGUID(p)},
+			PubDate:     publishedTime(p).UTC().Format(time.RFC1123Z),
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	return feed
+}
+
+func (s *Server) atomDocument(src *feedSource, lastModified time.Time) *atomFeed {
+	if lastModified.IsZero() {
+		lastModified = time.Unix(0, 0) // Atom要求必须有updated，没有文章时用一个固定值，保证ETag稳定
+	}
+	feed := &atomFeed{
+		Title:    src.title,
+		Subtitle: src.description,
+		ID:       src.id,
+		Updated:  lastModified.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: src.link, Rel: "alternate"},
+			{Href: src.self, Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: []atomEntry{},
+	}
+	for i := range src.posts {
+		p := &src.posts[i]
+		entry := atomEntry{
+			Title:     p.Title,
+			ID:        s.postGUID(p),
+			Updated:   p.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: publishedTime(p).UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Href: s.postURL(p.ID), Rel: "alternate"}},
+			Author:    atomPerson{Name: p.User.Username},
+			Content:   atomContent{Type: "html", Body: feedContent(p)},
+		}
+		for _, term := range postTerms(p) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: term})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+// postURL 文章的访问地址
+func (s *Server) postURL(id uint) string {
+	return strings.TrimRight(s.cfg.Feed.SiteURL, "/") + "/api/posts/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// postGUID 文章的唯一标识：tag:域名,创建日期:post:ID
+func (s *Server) postGUID(p *Post) string {
+	return tagURI(s.cfg.Feed.SiteURL, p.CreatedAt.UTC().Format("2006-01-02"), "post:"+strconv.FormatUint(uint64(p.ID), 10))
+}
+
+// tagURI 生成 RFC 4151 的tag URI，date形如 2026-01-02
+func tagURI(siteURL, date, specific string) string {
+	host := siteURL
+	if u, err := url.Parse(siteURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	return "tag:" + host + "," + date + ":" + specific
+}
+
+// publishedTime 文章的发布时间，老数据没有记录发布时间时用创建时间
+func publishedTime(p *Post) time.Time {
+	if p.PublishedAt != nil {
+		return *p.PublishedAt
+	}
+	return p.CreatedAt
+}
+
+// postTerms 文章的分类和标签，作为订阅条目的category
+func postTerms(p *Post) []string {
+	var terms []string
+	if p.Category != nil {
+		terms = append(terms, p.Category.Name)
+	}
+	for _, t := range p.Tags {
+		terms = append(terms, t.Name)
+	}
+	return terms
+}
+
+// feedContent 订阅里的正文：和文章详情一样渲染成过滤后的HTML
+func feedContent(p *Post) string {
+	html, _, err := renderContent(p.Format, p.Content)
+	if err != nil {
+		return renderPlain(p.Content)
+	}
+	return html
+}
+
+// ---------------------- 条件请求 ----------------------
+
+// writeConditional 带上ETag和Last-Modified返回body；客户端缓存的版本没变时返回304
+// If-None-Match优先：删除文章不会改变Last-Modified，但会改变ETag
+func writeConditional(c *gin.Context, contentType string, body []byte, lastModified time.Time) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	} else if ims := c.GetHeader("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		// HTTP时间只精确到秒
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// etagMatches If-None-Match里是否有匹配的ETag，按弱比较
+func etagMatches(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFeedLimit(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	for i := 0; i < 5; i++ {
+		sc.call("POST", "/api/posts", alice, gin.H{"title": fmt.Sprint("文章", i), "content": "内容"}, 200)
+	}
+	sc.call("POST", "/api/posts", alice, gin.H{"title": "草稿", "content": "内容", "status": StatusDraft}, 200)
+	s.cfg.Feed.Limit = 2
+
+	// 订阅里最多 feed.limit 篇已发布的文章，从最新的开始
+	cases := []struct {
+		url, item string
+	}{
+		{"/feed.xml", "<item>"},
+		{"/atom.xml", "<entry>"},
+		{"/api/users/1/feed", "<item>"},
+		{"/api/users/1/feed?format=atom", "<entry>"},
+	}
+	for _, tc := range cases {
+		body := sc.send(httptest.NewRequest("GET", tc.url, nil), "", 200).Body.String()
+		if n := strings.Count(body, tc.item); n != 2 {
+			t.Errorf("%s 有 %d 篇文章，期望 2 篇", tc.url, n)
+		}
+		if !strings.Contains(body, "文章4") || strings.Contains(body, "文章2") || strings.Contains(body, "草稿") {
+			t.Errorf("%s 的文章不对: %s", tc.url, body)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 关注：关注作者 + 关注动态 ======================
+// 用户之间单向关注，关注关系存在follows表，(follower_id, followee_id) 唯一
+// 关注动态 GET /api/feed 在查询时拉取：已发布且作者在关注列表里的文章，按创建时间倒序，只支持游标分页，
+// 用 posts.user_id IN (关注的人) 加上 posts(user_id, status, created_at) 索引，每个作者只扫最新的几篇，
+// 翻页用游标而不是OFFSET，关注的人和文章再多，每一页的代价也只和page_size有关
+
+// Follow 关注表
+type Follow struct {
+	ID         uint      `gorm:"primarykey"`
+	FollowerID uint      `gorm:"not null;uniqueIndex:idx_follow_pair"` // 关注者
+	FolloweeID uint      `gorm:"not null;uniqueIndex:idx_follow_pair;index"`
+	CreatedAt  time.Time // 关注的时间
+}
+
+// FollowView 关注列表和粉丝列表里的用户
+type FollowView struct {
+	ID         uint      `json:"id"`
+	Username   string    `json:"username"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// FollowQuery 关注列表和粉丝列表的分页参数，按关注时间倒序
+type FollowQuery struct {
+	Page     int `form:"page"`
+	PageSize int `form:"page_size"`
+}
+
+func (q *FollowQuery) normalize() {
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+}
+
+// FeedQuery 关注动态的分页参数，只支持游标分页
+type FeedQuery struct {
+	PageSize int    `form:"page_size"`
+	Cursor   string `form:"cursor"` // 取自上一页响应的 next_cursor
+}
+
+// followTarget 解析路径里的用户ID并确认用户存在
+func (s *Server) followTarget(c *gin.Context) (*User, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return nil, errInvalidUserID
+	}
+	user, err := s.Users.FindByID(c.Request.Context(), uint(id))
+	if errors.Is(err, ErrNotFound) {
+		return nil, errUserNotFound
+	}
+	if err != nil {
+		log.Errorf("查询用户失败: %v", err)
+		return nil, apperr.Internal("查询用户失败")
+	}
+	return user, nil
+}
+
+// FollowUser 关注用户 POST /api/users/:id/follow 【需要登录】
+// 已经关注过时同样返回成功
+func (s *Server) FollowUser(c *gin.Context) {
+	target, err := s.followTarget(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	userID := c.GetUint("userID")
+	if target.ID == userID {
+		fail(c, errFollowSelf)
+		return
+	}
+	created, err := s.Follows.Follow(c.Request.Context(), userID, target.ID)
+	if err != nil {
+		log.Errorf("关注失败: %v", err)
+		fail(c, apperr.Internal("关注失败"))
+		return
+	}
+	if created {
+		log.Infof("用户ID:%d 关注了用户ID:%d", userID, target.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "关注成功！"})
+}
+
+// UnfollowUser 取消关注 DELETE /api/users/:id/follow 【需要登录】
+// 本来就没有关注时同样返回成功
+func (s *Server) UnfollowUser(c *gin.Context) {
+	target, err := s.followTarget(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	userID := c.GetUint("userID")
+	removed, err := s.Follows.Unfollow(c.Request.Context(), userID, target.ID)
+	if err != nil {
+		log.Errorf("取消关注失败: %v", err)
+		fail(c, apperr.Internal("取消关注失败"))
+		return
+	}
+	if removed {
+		log.Infof("用户ID:%d 取消关注了用户ID:%d", userID, target.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已取消关注"})
+}
+
+// ListFollowers 粉丝列表 GET /api/users/:id/followers 【无需登录】
+func (s *Server) ListFollowers(c *gin.Context) {
+	s.listFollows(c, true)
+}
+
+// ListFollowing 关注列表 GET /api/users/:id/following 【无需登录】
+func (s *Server) ListFollowing(c *gin.Context) {
+	s.listFollows(c, false)
+}
+
+// listFollows followers为true时列出粉丝，否则列出关注的人
+func (s *Server) listFollows(c *gin.Context, followers bool) {
+	var q FollowQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	q.normalize()
+	user, err := s.followTarget(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	list := s.Follows.ListFollowing
+	if followers {
+		list = s.Follows.ListFollowers
+	}
+	users, total, err := list(c.Request.Context(), user.ID, &q)
+	if err != nil {
+		log.Errorf("获取关注列表失败: %v", err)
+		fail(c, apperr.Internal("获取关注列表失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":       200,
+		"msg":        "获取成功",
+		"data":       users,
+		"pagination": Pagination{Page: q.Page, PageSize: q.PageSize, Total: total},
+	})
+}
+
+// Feed 关注动态 GET /api/feed 【需要登录】
+// 关注的作者已发布的文章，按创建时间倒序；翻页用上一页的 next_cursor
+func (s *Server) Feed(c *gin.Context) {
+	var fq FeedQuery
+	if err := c.ShouldBindQuery(&fq); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	userID := c.GetUint("userID")
+	q := PostQuery{PageSize: fq.PageSize, Cursor: fq.Cursor, viewerID: userID, followerID: userID}
+	if err := q.Normalize(); err != nil {
+		fail(c, err)
+		return
+	}
+
+	posts, total, err := s.Posts.List(c.Request.Context(), &q)
+	if err != nil {
+		log.Errorf("获取关注动态失败: %v", err)
+		fail(c, apperr.Internal("获取关注动态失败"))
+		return
+	}
+	posts, page := q.Paginate(posts, total)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "获取成功", "data": s.viewPosts(c, posts), "pagination": page})
+}
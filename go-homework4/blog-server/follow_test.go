@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFollowAndFeed(t *testing.T) {
+	_, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	bob := sc.login("bob")
+	sc.call("POST", "/api/posts", alice, gin.H{"title": "Hello", "content": "hi"}, 200)
+	sc.call("POST", "/api/posts", alice, gin.H{"title": "Draft", "content": "wip", "status": StatusDraft}, 200)
+
+	// 重复关注不报错
+	sc.call("POST", "/api/users/1/follow", bob, nil, 200)
+	sc.call("POST", "/api/users/1/follow", bob, nil, 200)
+	sc.call("POST", "/api/users/2/follow", alice, nil, 200)
+	followers := sc.call("GET", "/api/users/1/followers", "", nil, 200)
+	if data := followers["data"].([]interface{}); len(data) != 1 || data[0].(map[string]interface{})["username"] != "bob" {
+		t.Errorf("粉丝列表不对: %v", data)
+	}
+	following := sc.call("GET", "/api/users/2/following?page_size=1", "", nil, 200)
+	if data := following["data"].([]interface{}); len(data) != 1 || data[0].(map[string]interface{})["username"] != "alice" {
+		t.Errorf("关注列表不对: %v", data)
+	}
+
+	// 关注动态只有关注的作者已发布的文章，按游标翻页
+	sc.call("POST", "/api/posts", alice, gin.H{"title": "Second", "content": "more"}, 200)
+	sc.call("POST", "/api/posts", bob, gin.H{"title": "Mine", "content": "own"}, 200)
+	feed := sc.call("GET", "/api/feed?page_size=1", bob, nil, 200)
+	if data := feed["data"].([]interface{}); len(data) != 1 || data[0].(map[string]interface{})["title"] != "Second" {
+		t.Errorf("关注动态第一页不对: %v", data)
+	}
+	next := feed["pagination"].(map[string]interface{})["next_cursor"].(string)
+	feed = sc.call("GET", "/api/feed?page_size=1&cursor="+next, bob, nil, 200)
+	if data := feed["data"].([]interface{}); len(data) != 1 || data[0].(map[string]interface{})["title"] != "Hello" {
+		t.Errorf("关注动态第二页不对: %v", data)
+	}
+	if next := feed["pagination"].(map[string]interface{})["next_cursor"]; next != "" {
+		t.Errorf("关注动态应该只有两页: %v", next)
+	}
+
+	sc.call("DELETE", "/api/users/1/follow", bob, nil, 200)
+	if data := sc.call("GET", "/api/feed", bob, nil, 200)["data"].([]interface{}); len(data) != 0 {
+		t.Errorf("取消关注后关注动态应该为空: %v", data)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 健康检查：存活探针和就绪探针 ======================
+// /healthz 只说明进程还活着，不检查依赖，失败时编排系统会重启容器
+// /readyz 检查数据库能连上并且表结构已迁移，失败时编排系统只是暂时不把流量转过来，不会重启
+// 开始优雅关闭后 /readyz 一律返回未就绪，负载均衡据此把流量摘掉
+
+// readyTimeout 就绪检查的超时，数据库卡住时探针也要及时返回
+const readyTimeout = 2 * time.Second
+
+// Healthz 存活探针 GET /healthz 【无需登录】
+func (s *Server) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "ok"})
+}
+
+// BeginShutdown 标记服务正在关闭，在 srv.Shutdown 之前调用
+func (s *Server) BeginShutdown() {
+	s.shuttingDown.Store(true)
+}
+
+// Readyz 就绪探针 GET /readyz 【无需登录】
+// 失败原因只写日志，不返回给调用方，避免把数据库的错误信息暴露出去
+func (s *Server) Readyz(c *gin.Context) {
+	if s.shuttingDown.Load() {
+		fail(c, errShuttingDown)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+	if err := s.Health.Ready(ctx); err != nil {
+		log.Warnf("就绪检查失败: %v", err)
+		fail(c, errNotReady)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "ok"})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// brokenHealth 模拟数据库连不上，错误信息里带着不能暴露出去的内部细节
+type brokenHealth struct{}
+
+func (brokenHealth) Ready(context.Context) error {
+	return errors.New("dial tcp 10.0.0.5:5432: password authentication failed for user \"blog\"")
+}
+
+func TestReadyz(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	sc.call("GET", "/readyz", "", nil, 200)
+
+	// 数据库的错误只写日志，响应里只有固定的信息
+	s.Health = brokenHealth{}
+	resp := sc.call("GET", "/readyz", "", nil, 503)
+	if code := errorCode(resp); code != errNotReady.Code {
+		t.Errorf("错误码 %s，期望 %s", code, errNotReady.Code)
+	}
+	if msg := resp["msg"].(string); strings.Contains(msg, "10.0.0.5") || msg != errNotReady.Message {
+		t.Errorf("就绪检查失败时暴露了内部错误: %s", msg)
+	}
+
+	// 开始关闭后一律未就绪，存活探针不受影响
+	s.Health = memoryHealthChecker{}
+	s.BeginShutdown()
+	resp = sc.call("GET", "/readyz", "", nil, 503)
+	if code := errorCode(resp); code != errShuttingDown.Code {
+		t.Errorf("关闭时的错误码 %s，期望 %s", code, errShuttingDown.Code)
+	}
+	sc.call("GET", "/healthz", "", nil, 200)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ====================== 邮件发送 ======================
+// 业务代码只依赖Mailer接口：线上用SMTP发送，开发和测试时用LogMailer把邮件写到日志或文件里，不需要真的发出去
+
+const (
+	MailDriverLog  = "log"
+	MailDriverSMTP = "smtp"
+)
+
+// Mail 一封纯文本邮件
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送接口
+type Mailer interface {
+	Send(ctx context.Context, m Mail) error
+}
+
+// NewMailer 按配置创建邮件发送器
+func NewMailer(cfg MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case MailDriverLog:
+		return NewLogMailer(cfg.LogFile), nil
+	case MailDriverSMTP:
+		return NewSMTPMailer(cfg.SMTP, cfg.From), nil
+	}
+	return nil, fmt.Errorf("不支持的邮件发送方式: %s", cfg.Driver)
+}
+
+// ---------------------- SMTP ----------------------
+
+// SMTPMailer 通过SMTP服务器发送邮件，服务器支持时自动升级为STARTTLS；配置了用户名时用PLAIN认证
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPMailer(cfg SMTPConfig, from string) *SMTPMailer {
+	m := &SMTPMailer{addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)), from: from}
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return m
+}
+
+// Send net/smtp不支持context，超时由SMTP服务器和TCP连接决定
+func (m *SMTPMailer) Send(_ context.Context, mail Mail) error {
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{mail.To}, formatMail(m.from, mail))
+}
+
+// formatMail 生成RFC 5322格式的邮件，主题按RFC 2047编码，正文UTF-8
+func formatMail(from string, mail Mail) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + mail.To + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", mail.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// ---------------------- 日志/文件 ----------------------
+
+// LogMailer 不发送邮件：path为空时把邮件打到日志里，否则追加写到path文件，开发和测试时从这里拿验证链接
+type LogMailer struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+func (m *LogMailer) Send(_ context.Context, mail Mail) error {
+	if m.path == "" {
+		log.Infof("邮件(未发送) 收件人:%s 主题:%s\n%s", mail.To, mail.Subject, mail.Body)
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "To: %s\nSubject: %s\n\n%s\n\n", mail.To, mail.Subject, mail.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// ====================== 监控指标：Prometheus /metrics ======================
+// 指标都注册在默认的Registry上（自带Go运行时和进程指标），所有名字以 blog_ 开头
+// 路由标签用注册时的写法（如 /api/posts/:id），不用实际路径，避免标签数量无限增长
+
+var (
+	// ---------------------- HTTP ----------------------
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "blog_http_requests_total",
+		Help: "HTTP请求数",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "blog_http_request_duration_seconds",
+		Help:    "HTTP请求耗时",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	httpRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "blog_http_requests_in_flight",
+		Help: "正在处理的HTTP请求数",
+	})
+
+	// ---------------------- 数据库 ----------------------
+	dbQueriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "blog_db_queries_total",
+		Help: "数据库操作次数，status为ok或error（查不到记录算ok）",
+	}, []string{"operation", "table", "status"})
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "blog_db_query_duration_seconds",
+		Help:    "数据库操作耗时",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	// ---------------------- 业务 ----------------------
+	usersRegisteredTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "blog_users_registered_total",
+		Help: "注册成功的用户数",
+	})
+	loginsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "blog_logins_total",
+		Help: "登录次数，result为success或failure",
+	}, []string{"result"})
+	postsCreatedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "blog_posts_created_total",
+		Help: "创建的文章数",
+	})
+	commentsCreatedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "blog_comments_created_total",
+		Help: "发表的评论数",
+	})
+)
+
+// MetricsMiddleware 记录每个请求的次数和耗时，挂在所有路由之前
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		httpRequestsInFlight.Inc()
+		c.Next()
+		httpRequestsInFlight.Dec()
+
+		// 没匹配到路由的请求（404）统一归到一个标签下，否则扫描器能把标签刷爆
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsHandler 输出指标的接口 GET /metrics 【无需登录，上线后应只允许监控系统访问】
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// RegisterDBStats 注册数据库连接池指标（go_sql_* 开头，如打开/空闲连接数、等待次数）
+// 每个进程只能调用一次，由main在连接数据库后调用
+func RegisterDBStats(db *gorm.DB, name string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return prometheus.Register(collectors.NewDBStatsCollector(sqlDB, name))
+}
+
+// ---------------------- GORM插件：统计每次数据库操作 ----------------------
+
+// dbMetricsPlugin 在GORM的create/query/update/delete/row/raw回调前后计时，openDB时通过 db.Use 注册
+type dbMetricsPlugin struct{}
+
+const dbMetricsStartKey = "metrics:start"
+
+func (dbMetricsPlugin) Name() string {
+	return "blog:metrics"
+}
+
+func (dbMetricsPlugin) Initialize(db *gorm.DB) error {
+	start := func(tx *gorm.DB) {
+		tx.InstanceSet(dbMetricsStartKey, time.Now())
+	}
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", start),
+		cb.Create().After("gorm:create").Register("metrics:after_create", observeQuery("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", start),
+		cb.Query().After("gorm:query").Register("metrics:after_query", observeQuery("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", start),
+		cb.Update().After("gorm:update").Register("metrics:after_update", observeQuery("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", start),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", observeQuery("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", start),
+		cb.Row().After("gorm:row").Register("metrics:after_row", observeQuery("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", start),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", observeQuery("raw")),
+	)
+}
+
+func observeQuery(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(dbMetricsStartKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+
+		table := tx.Statement.Table
+		if table == "" {
+			table = "none" // 手写SQL没有表名
+		}
+		status := "ok"
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			status = "error"
+		}
+		dbQueriesTotal.WithLabelValues(operation, table, status).Inc()
+		dbQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ====================== 数据库迁移：带版本号的SQL文件 ======================
+// 迁移文件在 migrations/<驱动>/ 下，命名为 <版本号>_<名称>.up.sql 和 .down.sql，编译时嵌入到程序里
+// 已执行的版本记录在 schema_migrations 表；每个版本在一个事务里执行，失败时整体回滚，不会留下执行了一半的版本
+// 新增或修改表结构时：在 postgres 和 sqlite 两个目录下各加一对版本号相同的文件，不要修改已经发布的文件
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string                  // 为空表示这个版本不能回滚
+	Before  func(tx *gorm.DB) error // 在Up的SQL之前、同一个事务里执行，SQL写不出来的步骤才用，见 migrationHooks
+}
+
+// migrationHooks 版本号 -> 执行SQL之前的Go代码
+var migrationHooks = map[int]func(tx *gorm.DB) error{
+	1: upgradeLegacyTables,
+}
+
+// legacyColumn 最早用 AutoMigrate 建的 users/posts/comments 表后来加的列
+type legacyColumn struct {
+	table, column string
+	postgres      string // 列定义
+	sqlite        string
+}
+
+// legacyColumns 0001_init 用 CREATE TABLE IF NOT EXISTS 建表，已经存在的旧表不会自动补上这些列，
+// 后面的 CREATE INDEX 就会因为列不存在而失败，所以要先补列；补的列不带外键约束
+var legacyColumns = []legacyColumn{
+	{"users", "role", "varchar(20) NOT NULL DEFAULT 'user'", "varchar(20) NOT NULL DEFAULT 'user'"},
+	{"posts", "format", "varchar(10) NOT NULL DEFAULT 'plain'", "varchar(10) NOT NULL DEFAULT 'plain'"},
+	{"posts", "category_id", "bigint", "integer"},
+	{"posts", "status", "varchar(20) NOT NULL DEFAULT 'published'", "varchar(20) NOT NULL DEFAULT 'published'"},
+	{"posts", "publish_at", "timestamptz", "datetime"},
+	{"posts", "published_at", "timestamptz", "datetime"},
+	{"comments", "parent_id", "bigint", "integer"},
+	{"comments", "depth", "bigint NOT NULL DEFAULT 0", "integer NOT NULL DEFAULT 0"},
+}
+
+// upgradeLegacyTables 给以前 AutoMigrate 建的旧表补上缺少的列，新数据库和已经有这些列的表不受影响
+// 老文章补列后是 published 状态、plain 格式，和功能上线前的行为一致
+func upgradeLegacyTables(tx *gorm.DB) error {
+	driver := tx.Dialector.Name()
+	for _, col := range legacyColumns {
+		if !tx.Migrator().HasTable(col.table) || tx.Migrator().HasColumn(col.table, col.column) {
+			continue
+		}
+		def := col.sqlite
+		if driver == "postgres" {
+			def = col.postgres
+		}
+		if err := tx.Exec("ALTER TABLE " + col.table + " ADD COLUMN " + col.column + " " + def).Error; err != nil {
+			return fmt.Errorf("给旧表 %s 补列 %s 失败: %w", col.table, col.column, err)
+		}
+		log.Infof("已给旧表 %s 补上列 %s", col.table, col.column)
+	}
+	return nil
+}
+
+// String 返回和文件名一致的 0001_init 形式
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus 一个版本的执行状态
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // 为空表示还没执行
+	Unknown   bool       // 数据库里有记录但程序里没有这个版本，一般是数据库被更新的版本迁移过
+}
+
+// schemaMigration schema_migrations表的一行
+type schemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null;type:varchar(255)"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator 执行某个数据库的迁移
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration // 按版本号升序
+}
+
+// NewMigrator 按数据库驱动加载对应目录下的迁移文件
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, "migrations/"+db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	for i := range migrations {
+		migrations[i].Before = migrationHooks[migrations[i].Version]
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// loadMigrations 读取目录下的迁移文件，按版本号排序
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移目录 %s 失败: %w", dir, err)
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := migrationName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			return nil, fmt.Errorf("迁移文件名 %s/%s 不符合 <版本号>_<名称>.up.sql 或 .down.sql 的格式", dir, e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		data, err := fs.ReadFile(fsys, dir+"/"+e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("迁移版本 %d 有两个不同的名称：%s 和 %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("迁移 %s 缺少 up 文件", mig)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// applied 返回数据库里已执行的版本，schema_migrations表不存在时自动创建
+func (m *Migrator) applied(ctx context.Context) (map[int]schemaMigration, error) {
+	db := m.db.WithContext(ctx)
+	if err := db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version bigint PRIMARY KEY, name varchar(255) NOT NULL, applied_at timestamp NOT NULL)").Error; err != nil {
+		return nil, fmt.Errorf("创建 schema_migrations 表失败: %w", err)
+	}
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]schemaMigration, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// Status 返回所有版本的执行状态，按版本号升序
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list []MigrationStatus
+	for _, mig := range m.migrations {
+		st := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			st.AppliedAt = &r.AppliedAt
+			delete(applied, mig.Version)
+		}
+		list = append(list, st)
+	}
+	for _, r := range applied {
+		list = append(list, MigrationStatus{Version: r.Version, Name: r.Name, AppliedAt: &r.AppliedAt, Unknown: true})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Pending 返回还没执行的版本，按版本号升序
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Up 按版本号从小到大执行还没执行的版本，steps<=0表示全部执行，返回执行了几个版本
+func (m *Migrator) Up(ctx context.Context, steps int) (int, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if steps > 0 && steps < len(pending) {
+		pending = pending[:steps]
+	}
+	for i, mig := range pending {
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if mig.Before != nil {
+				if err := mig.Before(tx); err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec(mig.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return i, fmt.Errorf("执行迁移 %s 失败: %w", mig, err)
+		}
+		log.Infof("已执行迁移 %s", mig)
+	}
+	return len(pending), nil
+}
+
+// Down 从最新的版本开始回滚steps个已执行的版本，返回回滚了几个版本
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		if _, ok := applied[m.migrations[i].Version]; ok {
+			done = append(done, m.migrations[i])
+		}
+	}
+	for i, mig := range done {
+		if mig.Down == "" {
+			return i, fmt.Errorf("迁移 %s 没有 down 文件，不能回滚", mig)
+		}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(mig.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Version: mig.Version}).Error
+		})
+		if err != nil {
+			return i, fmt.Errorf("回滚迁移 %s 失败: %w", mig, err)
+		}
+		log.Infof("已回滚迁移 %s", mig)
+	}
+	return len(done), nil
+}
+
+// Check 检查表结构是否是最新的，有没执行的版本时返回错误，服务不应该启动或接收流量
+// 数据库比程序新（回退了程序版本）时只打警告，新版本的迁移一般兼容旧程序
+func (m *Migrator) Check(ctx context.Context) error {
+	list, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, st := range list {
+		switch {
+		case st.Unknown:
+			log.Warnf("数据库里有程序不认识的迁移版本 %04d_%s，可能是被更新版本的程序迁移过", st.Version, st.Name)
+		case st.AppliedAt == nil:
+			pending = append(pending, fmt.Sprintf("%04d_%s", st.Version, st.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("数据库表结构落后 %d 个版本（%s），请先执行 migrate up", len(pending), strings.Join(pending, "、"))
+	}
+	return nil
+}
+
+// ---------------------- migrate 子命令 ----------------------
+
+// migrateCommand 解析好的 migrate 子命令
+type migrateCommand struct {
+	action string // up / down / status
+	steps  int
+}
+
+// parseMigrateArgs 解析 migrate 后面的参数：up [N] / down [N] / status
+// up默认执行全部未执行的版本，down默认只回滚最新的一个版本；在连接数据库之前调用，参数写错时尽早报错
+func parseMigrateArgs(args []string) (*migrateCommand, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, errors.New("用法：migrate up [N] | migrate down [N] | migrate status；-config 等参数要写在 migrate 前面")
+	}
+	cmd := &migrateCommand{action: args[0]}
+	switch cmd.action {
+	case "up":
+	case "down":
+		cmd.steps = 1
+	case "status":
+		if len(args) == 2 {
+			return nil, errors.New("migrate status 不接受参数；-config 等参数要写在 migrate 前面")
+		}
+	default:
+		return nil, fmt.Errorf("未知的 migrate 子命令 %q，只支持 up、down、status", cmd.action)
+	}
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("版本数 %q 必须是正整数；-config 等参数要写在 migrate 前面", args[1])
+		}
+		cmd.steps = n
+	}
+	return cmd, nil
+}
+
+// run 执行子命令，状态表格输出到标准输出
+func (cmd *migrateCommand) run(ctx context.Context, m *Migrator) error {
+	switch cmd.action {
+	case "up":
+		n, err := m.Up(ctx, cmd.steps)
+		if err != nil {
+			return err
+		}
+		log.Infof("迁移完成，执行了 %d 个版本", n)
+	case "down":
+		n, err := m.Down(ctx, cmd.steps)
+		if err != nil {
+			return err
+		}
+		log.Infof("回滚完成，回滚了 %d 个版本", n)
+	case "status":
+		list, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "版本\t名称\t状态\t执行时间")
+		for _, st := range list {
+			state, at := "未执行", ""
+			if st.AppliedAt != nil {
+				state, at = "已执行", st.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			if st.Unknown {
+				state = "未知版本"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, state, at)
+		}
+		return w.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// legacySchema 最早的版本用 AutoMigrate 在SQLite上建出的三张表
+const legacySchema = `
+CREATE TABLE users (
+    id integer PRIMARY KEY AUTOINCREMENT, created_at datetime, updated_at datetime, deleted_at datetime,
+    username varchar(50) NOT NULL, password varchar(100) NOT NULL, email varchar(100) NOT NULL,
+    CONSTRAINT uni_users_username UNIQUE (username), CONSTRAINT uni_users_email UNIQUE (email)
+);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+CREATE TABLE posts (
+    id integer PRIMARY KEY AUTOINCREMENT, created_at datetime, updated_at datetime, deleted_at datetime,
+    title varchar(100) NOT NULL, content text NOT NULL, user_id integer NOT NULL,
+    CONSTRAINT fk_posts_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX idx_posts_deleted_at ON posts (deleted_at);
+CREATE TABLE comments (
+    id integer PRIMARY KEY AUTOINCREMENT, created_at datetime, updated_at datetime, deleted_at datetime,
+    content text NOT NULL, user_id integer NOT NULL, post_id integer NOT NULL,
+    CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts (id)
+);
+CREATE INDEX idx_comments_deleted_at ON comments (deleted_at);
+INSERT INTO users (id, created_at, updated_at, username, password, email) VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 'alice', 'x', 'alice@example.com');
+INSERT INTO posts (id, created_at, updated_at, title, content, user_id) VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, '老文章', '内容', 1);
+INSERT INTO comments (id, created_at, updated_at, content, user_id, post_id) VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, '老评论', 1, 1);
+`
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	ctx := context.Background()
+	cfg := defaultConfig()
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.DSN = t.TempDir() + "/legacy.db"
+	db, err := openDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(legacySchema).Error; err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := len(m.migrations)
+
+	// 旧数据库直接 migrate up，缺少的列补上，数据保留
+	if n, err := m.Up(ctx, 0); err != nil || n != all {
+		t.Fatalf("migrate up 执行了 %d 个版本: %v", n, err)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	repos := NewGormRepositories(db)
+	user, err := repos.Users.FindByID(ctx, 1)
+	if err != nil || user.Role != RoleUser {
+		t.Fatalf("老用户的角色不对: %+v %v", user, err)
+	}
+	post, err := repos.Posts.FindByID(ctx, 1)
+	if err != nil || post.Title != "老文章" || post.Status != StatusPublished || post.Format != FormatPlain {
+		t.Fatalf("老文章不对: %+v %v", post, err)
+	}
+	comments, err := repos.Comments.ListByPost(ctx, 1)
+	if err != nil || len(comments) != 1 || comments[0].ParentID != nil || comments[0].Depth != 0 {
+		t.Fatalf("老评论不对: %+v %v", comments, err)
+	}
+
+	// 全部回滚之后还能重新迁移
+	if n, err := m.Down(ctx, all); err != nil || n != all {
+		t.Fatalf("migrate down 回滚了 %d 个版本: %v", n, err)
+	}
+	if db.Migrator().HasTable("posts") {
+		t.Error("回滚 0001_init 之后表还在")
+	}
+	if n, err := m.Up(ctx, 0); err != nil || n != all {
+		t.Fatalf("回滚后 migrate up 执行了 %d 个版本: %v", n, err)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,12 @@
+-- 删除全部业务表，数据会全部丢失
+DROP TABLE IF EXISTS attachments;
+DROP TABLE IF EXISTS post_revisions;
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS post_tags;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,143 @@
+-- 初始表结构，和之前 AutoMigrate 建出来的表完全一致
+-- 全部用 IF NOT EXISTS，以前用 AutoMigrate 建过的表不会重建，数据不受影响；
+-- 最早版本建的 users/posts/comments 缺少后来加的列，执行这个文件之前由 migrate.go 的 upgradeLegacyTables 补上
+
+CREATE TABLE IF NOT EXISTS users (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    username   varchar(50)  NOT NULL,
+    password   varchar(100) NOT NULL,
+    email      varchar(100) NOT NULL,
+    role       varchar(20)  NOT NULL DEFAULT 'user',
+    CONSTRAINT uni_users_username UNIQUE (username),
+    CONSTRAINT uni_users_email UNIQUE (email)
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id         bigserial PRIMARY KEY,
+    name       varchar(50) NOT NULL,
+    created_at timestamptz,
+    CONSTRAINT uni_categories_name UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id         bigserial PRIMARY KEY,
+    name       varchar(50) NOT NULL,
+    created_at timestamptz,
+    CONSTRAINT uni_tags_name UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id           bigserial PRIMARY KEY,
+    created_at   timestamptz,
+    updated_at   timestamptz,
+    deleted_at   timestamptz,
+    title        varchar(100) NOT NULL,
+    content      text         NOT NULL,
+    user_id      bigint       NOT NULL,
+    format       varchar(10)  NOT NULL DEFAULT 'plain',
+    category_id  bigint,
+    status       varchar(20)  NOT NULL DEFAULT 'published',
+    publish_at   timestamptz,
+    published_at timestamptz,
+    CONSTRAINT fk_posts_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_posts_category FOREIGN KEY (category_id) REFERENCES categories (id)
+);
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_posts_category_id ON posts (category_id);
+CREATE INDEX IF NOT EXISTS idx_posts_status ON posts (status);
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts (publish_at);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id bigint,
+    tag_id  bigint,
+    PRIMARY KEY (post_id, tag_id),
+    CONSTRAINT fk_post_tags_post FOREIGN KEY (post_id) REFERENCES posts (id),
+    CONSTRAINT fk_post_tags_tag FOREIGN KEY (tag_id) REFERENCES tags (id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    content    text   NOT NULL,
+    user_id    bigint NOT NULL,
+    post_id    bigint NOT NULL,
+    parent_id  bigint,
+    depth      bigint NOT NULL DEFAULT 0,
+    CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts (id)
+);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id);
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id         bigserial PRIMARY KEY,
+    token_hash varchar(64) NOT NULL,
+    user_id    bigint      NOT NULL,
+    family_id  varchar(32) NOT NULL,
+    access_jti varchar(32),
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_access_jti ON refresh_tokens (access_jti);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti        varchar(32) PRIMARY KEY,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id          bigserial PRIMARY KEY,
+    actor_id    bigint      NOT NULL,
+    actor_role  varchar(20) NOT NULL,
+    action      varchar(50) NOT NULL,
+    target_type varchar(20) NOT NULL,
+    target_id   bigint      NOT NULL,
+    detail      text,
+    created_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id         bigserial PRIMARY KEY,
+    post_id    bigint       NOT NULL,
+    rev        bigint       NOT NULL,
+    title      varchar(100) NOT NULL,
+    content    text         NOT NULL,
+    editor_id  bigint       NOT NULL,
+    created_at timestamptz,
+    CONSTRAINT fk_post_revisions_editor FOREIGN KEY (editor_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_rev ON post_revisions (post_id, rev);
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id           bigserial PRIMARY KEY,
+    user_id      bigint       NOT NULL,
+    post_id      bigint,
+    hash         varchar(64)  NOT NULL,
+    filename     varchar(255) NOT NULL,
+    content_type varchar(100) NOT NULL,
+    size         bigint       NOT NULL,
+    width        bigint,
+    height       bigint,
+    key          varchar(255) NOT NULL,
+    thumb_key    varchar(255),
+    created_at   timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attachment_user_hash ON attachments (user_id, hash);
+CREATE INDEX IF NOT EXISTS idx_attachments_post_id ON attachments (post_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_hash ON attachments (hash);
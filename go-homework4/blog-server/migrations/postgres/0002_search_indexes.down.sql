@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_comments_fts;
+DROP INDEX IF EXISTS idx_posts_fts;
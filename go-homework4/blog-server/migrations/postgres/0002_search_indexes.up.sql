@@ -0,0 +1,4 @@
+-- 全文搜索的表达式索引，以前在 NewSearcher 里启动时创建
+-- 表达式必须和 search.go 里 pgPostVector / pgCommentVector 生成的完全一致，查询规划器才能用上
+CREATE INDEX IF NOT EXISTS idx_posts_fts ON posts USING GIN ((setweight(to_tsvector('simple', coalesce(title, '')), 'A') || setweight(to_tsvector('simple', coalesce(content, '')), 'B')));
+CREATE INDEX IF NOT EXISTS idx_comments_fts ON comments USING GIN ((to_tsvector('simple', coalesce(content, ''))));
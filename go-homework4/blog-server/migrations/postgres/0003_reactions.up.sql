@@ -0,0 +1,11 @@
+-- 表态：同一个用户对同一个目标的每种表态最多一条
+CREATE TABLE IF NOT EXISTS reactions (
+    id          bigserial PRIMARY KEY,
+    user_id     bigint      NOT NULL,
+    target_type varchar(20) NOT NULL,
+    target_id   bigint      NOT NULL,
+    kind        varchar(20) NOT NULL,
+    created_at  timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reaction_unique ON reactions (user_id, target_type, target_id, kind);
+CREATE INDEX IF NOT EXISTS idx_reactions_target ON reactions (target_type, target_id);
@@ -0,0 +1,12 @@
+-- 关注关系：(follower_id, followee_id) 唯一，同时用来查一个人关注了谁；followee_id 上的索引用来查粉丝
+CREATE TABLE IF NOT EXISTS follows (
+    id          bigserial PRIMARY KEY,
+    follower_id bigint      NOT NULL,
+    followee_id bigint      NOT NULL,
+    created_at  timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_follow_pair ON follows (follower_id, followee_id);
+CREATE INDEX IF NOT EXISTS idx_follows_followee_id ON follows (followee_id);
+
+-- 关注动态按作者取最新的已发布文章
+CREATE INDEX IF NOT EXISTS idx_posts_user_status_created ON posts (user_id, status, created_at);
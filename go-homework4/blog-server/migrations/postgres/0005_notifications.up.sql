@@ -0,0 +1,22 @@
+-- 站内通知：read_at 为空表示未读，(user_id, read_at) 索引用来列通知和统计未读数
+CREATE TABLE IF NOT EXISTS notifications (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint      NOT NULL,
+    actor_id   bigint      NOT NULL,
+    type       varchar(20) NOT NULL,
+    post_id    bigint      NOT NULL,
+    comment_id bigint      NOT NULL,
+    read_at    timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_read ON notifications (user_id, read_at);
+
+-- 通知偏好：每个用户每个类别一行，没有记录表示不屏蔽
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint      NOT NULL,
+    type       varchar(20) NOT NULL,
+    muted      boolean     NOT NULL DEFAULT false,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_pref ON notification_preferences (user_id, type);
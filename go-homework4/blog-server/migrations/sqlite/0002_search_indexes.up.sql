@@ -0,0 +1,2 @@
+-- SQLite的全文搜索用进程内的倒排索引（见 search.go），不需要建索引；保留这一版是为了和PostgreSQL的版本号保持一致
+SELECT 1;
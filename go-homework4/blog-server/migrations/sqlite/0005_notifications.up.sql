@@ -0,0 +1,22 @@
+-- 站内通知：read_at 为空表示未读，(user_id, read_at) 索引用来列通知和统计未读数
+CREATE TABLE IF NOT EXISTS notifications (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    user_id    integer     NOT NULL,
+    actor_id   integer     NOT NULL,
+    type       varchar(20) NOT NULL,
+    post_id    integer     NOT NULL,
+    comment_id integer     NOT NULL,
+    read_at    datetime,
+    created_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_read ON notifications (user_id, read_at);
+
+-- 通知偏好：每个用户每个类别一行，没有记录表示不屏蔽
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    user_id    integer     NOT NULL,
+    type       varchar(20) NOT NULL,
+    muted      boolean     NOT NULL DEFAULT false,
+    updated_at datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_pref ON notification_preferences (user_id, type);
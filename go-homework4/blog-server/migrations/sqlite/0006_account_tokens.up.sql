@@ -0,0 +1,16 @@
+-- 邮箱验证：为空表示还没验证
+ALTER TABLE users ADD COLUMN email_verified_at datetime;
+
+-- 邮箱验证和重置密码的一次性令牌，只存令牌的sha256；used_at 非空表示已使用或已作废
+CREATE TABLE IF NOT EXISTS account_tokens (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    user_id    integer      NOT NULL,
+    purpose    varchar(20)  NOT NULL,
+    token_hash varchar(64)  NOT NULL,
+    email      varchar(100) NOT NULL,
+    expires_at datetime     NOT NULL,
+    used_at    datetime,
+    created_at datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_tokens_token_hash ON account_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_account_tokens_user ON account_tokens (user_id, purpose);
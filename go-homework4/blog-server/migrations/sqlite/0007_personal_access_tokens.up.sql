@@ -0,0 +1,15 @@
+-- 个人访问令牌：只存令牌的sha256，scopes 空格分隔；expires_at 为空表示永不过期
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id           integer PRIMARY KEY AUTOINCREMENT,
+    user_id      integer      NOT NULL,
+    name         varchar(100) NOT NULL,
+    token_hash   varchar(64)  NOT NULL,
+    hint         varchar(20)  NOT NULL,
+    scopes       varchar(100) NOT NULL,
+    expires_at   datetime,
+    last_used_at datetime,
+    revoked_at   datetime,
+    created_at   datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_tokens_token_hash ON personal_access_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens (user_id);
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 站内通知：评论、回复、@提及 ======================
+// 发表评论成功后生成通知：文章作者收到 comment，被回复的评论作者收到 reply，评论里 @用户名 提到的人收到 mention
+// 同一条评论给同一个人只发一条，优先级 reply > comment > mention；自己触发的不通知自己
+// 每个用户可以屏蔽某几类通知，屏蔽的类别直接不生成；生成通知失败只记日志，不影响评论本身
+
+const (
+	NotifyComment = "comment" // 有人评论了我的文章
+	NotifyReply   = "reply"   // 有人回复了我的评论
+	NotifyMention = "mention" // 有人在评论里@了我
+)
+
+// NotificationTypes 全部通知类别，偏好设置按这个顺序返回
+var NotificationTypes = []string{NotifyComment, NotifyReply, NotifyMention}
+
+const maxMentionsPerComment = 10 // 一条评论最多通知几个被@的人，防止刷屏
+
+// mentionPattern 匹配 @用户名；@前面是字母数字时不算（比如邮箱地址）
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([\p{L}\p{N}_.\-]{1,50})`)
+
+// Notification 通知表
+type Notification struct {
+	ID        uint       `gorm:"primarykey"`
+	UserID    uint       `gorm:"not null;index:idx_notifications_user_read"` // 接收人
+	ActorID   uint       `gorm:"not null"`                                   // 触发通知的人
+	Actor     User       `gorm:"foreignKey:ActorID"`
+	Type      string     `gorm:"not null;type:varchar(20)"` // comment/reply/mention
+	PostID    uint       `gorm:"not null"`
+	CommentID uint       `gorm:"not null"`
+	ReadAt    *time.Time `gorm:"index:idx_notifications_user_read"` // 为空表示未读
+	CreatedAt time.Time
+}
+
+// NotificationPreference 通知偏好表，每个用户每个类别一行，没有记录表示不屏蔽
+type NotificationPreference struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"not null;uniqueIndex:idx_notification_pref"`
+	Type      string `gorm:"not null;type:varchar(20);uniqueIndex:idx_notification_pref"`
+	Muted     bool   `gorm:"not null;default:false"`
+	UpdatedAt time.Time
+}
+
+// NotificationQuery 通知列表的查询参数，按时间倒序
+type NotificationQuery struct {
+	Page     int  `form:"page"`
+	PageSize int  `form:"page_size"`
+	Unread   bool `form:"unread"` // true时只返回未读的
+}
+
+func (q *NotificationQuery) normalize() {
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+}
+
+// NotificationView 响应里的一条通知
+type NotificationView struct {
+	ID        uint       `json:"id"`
+	Type      string     `json:"type"`
+	Actor     UserView   `json:"actor"`
+	PostID    uint       `json:"post_id"`
+	CommentID uint       `json:"comment_id"`
+	Read      bool       `json:"read"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func notificationView(n *Notification) NotificationView {
+	return NotificationView{
+		ID:        n.ID,
+		Type:      n.Type,
+		Actor:     UserView{ID: n.ActorID, Username: n.Actor.Username},
+		PostID:    n.PostID,
+		CommentID: n.CommentID,
+		Read:      n.ReadAt != nil,
+		ReadAt:    n.ReadAt,
+		CreatedAt: n.CreatedAt,
+	}
+}
+
+// NotificationList 通知列表接口的data：这一页的通知和未读数量
+type NotificationList struct {
+	Unread       int64              `json:"unread"`         // 全部未读的数量，不受分页和unread参数影响
+	UnreadByType map[string]int64   `json:"unread_by_type"` // 每个类别未读的数量，没有未读的类别为0
+	Items        []NotificationView `json:"items"`
+}
+
+// NotificationPreferenceView 一个类别的通知偏好
+type NotificationPreferenceView struct {
+	Type  string `json:"type"`
+	Muted bool   `json:"muted"`
+}
+
+// NotificationPreferencesRequest 修改通知偏好的请求体，muted是要屏蔽的全部类别，没列出的类别恢复通知
+type NotificationPreferencesRequest struct {
+	Muted []string `json:"muted" binding:"required,dive,oneof=comment reply mention"`
+}
+
+// parseMentions 取出内容里@到的用户名，去重并保持出现顺序，最多maxMentionsPerComment个
+func parseMentions(content string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.TrimRight(m[1], ".-") // 句末的标点不算用户名
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		if len(names) == maxMentionsPerComment {
+			break
+		}
+	}
+	return names
+}
+
+// notifyComment 发表评论后生成通知；parent是回复的评论，顶层评论为nil
+func (s *Server) notifyComment(ctx context.Context, comment *Comment, post *Post, parent *Comment) error {
+	recipients := map[uint]string{} // 接收人 -> 通知类别
+	var order []uint
+	add := func(userID uint, typ string) {
+		if _, ok := recipients[userID]; ok || userID == comment.UserID {
+			return
+		}
+		recipients[userID] = typ
+		order = append(order, userID)
+	}
+	if parent != nil {
+		add(parent.UserID, NotifyReply)
+	}
+	add(post.UserID, NotifyComment)
+	for _, name := range parseMentions(comment.Content) {
+		user, err := s.Users.FindByUsername(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// 未发布的文章别人看不到，只通知作者自己
+		if post.Status == StatusPublished || user.ID == post.UserID {
+			add(user.ID, NotifyMention)
+		}
+	}
+
+	var notifications []Notification
+	for _, userID := range order {
+		typ := recipients[userID]
+		muted, err := s.Notifications.MutedTypes(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if muted[typ] {
+			continue
+		}
+		notifications = append(notifications, Notification{UserID: userID, ActorID: comment.UserID, Type: typ,
+			PostID: post.ID, CommentID: comment.ID})
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+	return s.Notifications.Create(ctx, notifications)
+}
+
+// ListNotifications 我的通知 GET /api/notifications 【需要登录】
+// 支持 page/page_size 分页，unread=true 时只返回未读的；data里同时带上未读数量
+func (s *Server) ListNotifications(c *gin.Context) {
+	var q NotificationQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	q.normalize()
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+
+	notifications, total, err := s.Notifications.List(ctx, userID, &q)
+	if err != nil {
+		log.Errorf("获取通知失败: %v", err)
+		fail(c, apperr.Internal("获取通知失败"))
+		return
+	}
+	unread, err := s.Notifications.UnreadCounts(ctx, userID)
+	if err != nil {
+		log.Errorf("统计未读通知失败: %v", err)
+		fail(c, apperr.Internal("获取通知失败"))
+		return
+	}
+
+	list := NotificationList{UnreadByType: map[string]int64{}, Items: make([]NotificationView, len(notifications))}
+	for _, typ := range NotificationTypes {
+		list.UnreadByType[typ] = unread[typ]
+		list.Unread += unread[typ]
+	}
+	for i := range notifications {
+		list.Items[i] = notificationView(&notifications[i])
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":       200,
+		"msg":        "获取成功",
+		"data":       list,
+		"pagination": Pagination{Page: q.Page, PageSize: q.PageSize, Total: total},
+	})
+}
+
+// MarkNotificationRead 标记一条通知为已读 POST /api/notifications/:id/read 【需要登录】
+// 已经读过的再标记一次也返回成功；别人的通知当作不存在
+func (s *Server) MarkNotificationRead(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, errInvalidNotificationID)
+		return
+	}
+	err = s.Notifications.MarkRead(c.Request.Context(), c.GetUint("userID"), uint(id), time.Now())
+	if errors.Is(err, ErrNotFound) {
+		fail(c, errNotificationNotFound)
+		return
+	}
+	if err != nil {
+		log.Errorf("标记通知已读失败: %v", err)
+		fail(c, apperr.Internal("标记已读失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已标记为已读"})
+}
+
+// MarkAllNotificationsRead 全部标记为已读 POST /api/notifications/read-all 【需要登录】
+func (s *Server) MarkAllNotificationsRead(c *gin.Context) {
+	n, err := s.Notifications.MarkAllRead(c.Request.Context(), c.GetUint("userID"), time.Now())
+	if err != nil {
+		log.Errorf("标记全部通知已读失败: %v", err)
+		fail(c, apperr.Internal("标记已读失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已将" + strconv.FormatInt(n, 10) + "条通知标记为已读"})
+}
+
+// preferenceViews 按 NotificationTypes 的顺序列出每个类别是否屏蔽
+func preferenceViews(muted map[string]bool) []NotificationPreferenceView {
+	views := make([]NotificationPreferenceView, len(NotificationTypes))
+	for i, typ := range NotificationTypes {
+		views[i] = NotificationPreferenceView{Type: typ, Muted: muted[typ]}
+	}
+	return views
+}
+
+// GetNotificationPreferences 查看通知偏好 GET /api/notifications/preferences 【需要登录】
+func (s *Server) GetNotificationPreferences(c *gin.Context) {
+	muted, err := s.Notifications.MutedTypes(c.Request.Context(), c.GetUint("userID"))
+	if err != nil {
+		log.Errorf("获取通知偏好失败: %v", err)
+		fail(c, apperr.Internal("获取通知偏好失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "获取成功", "data": preferenceViews(muted)})
+}
+
+// UpdateNotificationPreferences 修改通知偏好 PUT /api/notifications/preferences 【需要登录】
+// body：{"muted":["mention"]}，传空数组表示全部恢复
+func (s *Server) UpdateNotificationPreferences(c *gin.Context) {
+	var req NotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	muted := make(map[string]bool, len(req.Muted))
+	for _, typ := range req.Muted {
+		muted[typ] = true
+	}
+	if err := s.Notifications.SetMuted(c.Request.Context(), c.GetUint("userID"), muted); err != nil {
+		log.Errorf("修改通知偏好失败: %v", err)
+		fail(c, apperr.Internal("修改通知偏好失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功！", "data": preferenceViews(muted)})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNotifications(t *testing.T) {
+	_, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	bob := sc.login("bob")
+	root := sc.login("root")
+	postID := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "Hello", "content": "hi"}, 200), "id")
+
+	// bob的评论通知alice，alice的回复通知bob
+	top := sc.id(sc.call("POST", "/api/comments", bob, gin.H{"post_id": postID, "content": "nice"}, 200), "id")
+	sc.call("POST", "/api/comments", alice, gin.H{"post_id": postID, "parent_id": top, "content": "thanks"}, 200)
+	notes := sc.call("GET", "/api/notifications", bob, nil, 200)["data"].(map[string]interface{})
+	if notes["unread"] != float64(1) || notes["items"].([]interface{})[0].(map[string]interface{})["type"] != NotifyReply {
+		t.Errorf("bob的通知不对: %v", notes)
+	}
+
+	// @提到root；root屏蔽mention后不再收到，不存在的用户忽略
+	sc.call("POST", "/api/comments", alice, gin.H{"post_id": postID, "content": "cc @root @nobody"}, 200)
+	sc.call("PUT", "/api/notifications/preferences", root, gin.H{"muted": []string{NotifyMention}}, 200)
+	sc.call("POST", "/api/comments", alice, gin.H{"post_id": postID, "content": "@root again"}, 200)
+	notes = sc.call("GET", "/api/notifications?unread=true", root, nil, 200)["data"].(map[string]interface{})
+	items := notes["items"].([]interface{})
+	if len(items) != 1 || notes["unread_by_type"].(map[string]interface{})[NotifyMention] != float64(1) {
+		t.Fatalf("root的通知不对: %v", notes)
+	}
+
+	// 只能标记自己的通知，重复标记不报错
+	noteID := fmt.Sprint(items[0].(map[string]interface{})["id"])
+	sc.call("POST", "/api/notifications/"+noteID+"/read", bob, nil, 404)
+	sc.call("POST", "/api/notifications/"+noteID+"/read", root, nil, 200)
+	sc.call("POST", "/api/notifications/"+noteID+"/read", root, nil, 200)
+	if n := sc.call("GET", "/api/notifications", root, nil, 200)["data"].(map[string]interface{})["unread"]; n != float64(0) {
+		t.Errorf("标记已读后还有 %v 条未读", n)
+	}
+
+	sc.call("POST", "/api/notifications/read-all", alice, nil, 200)
+	if n := sc.call("GET", "/api/notifications", alice, nil, 200)["data"].(map[string]interface{})["unread"]; n != float64(0) {
+		t.Errorf("全部已读后还有 %v 条未读", n)
+	}
+}
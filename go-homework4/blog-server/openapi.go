@@ -0,0 +1,635 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	"gorm.io/gorm"
+)
+
+// ====================== 接口文档：OpenAPI 3 ======================
+// 文档不手写，而是由路由表(r.Routes()) + 下面 apiDocs 里登记的请求/响应类型通过反射生成，结构体改了文档自动跟着变
+// GET /openapi.json 返回文档，GET /docs/ 是内嵌的 Swagger UI
+// 开启 openapi.validate 后每个请求和响应都按文档校验，不一致时记日志；测试里用它保证文档和实现一致
+
+// authMode 接口的登录要求
+type authMode int
+
+const (
+	authNone     authMode = iota // 无需登录
+	authOptional                 // 带了token就识别当前用户，能看到更多内容
+	authRequired                 // 需要登录
+)
+
+// apiParam 没有放在查询参数结构体里、由handler直接 c.Query 读取的参数
+type apiParam struct {
+	Name     string
+	Schema   *openapi3.Schema
+	Desc     string
+	Required bool
+}
+
+// apiDoc 一个接口的文档
+type apiDoc struct {
+	Summary string
+	Tag     string
+	Auth    authMode
+
+	Query        interface{} // 查询参数结构体，按form tag生成参数
+	Params       []apiParam  // 其它查询参数
+	Body         interface{} // JSON请求体的类型
+	OptionalBody bool        // 请求体可以不传
+	Form         []apiParam  // multipart/form-data 请求体的字段
+
+	Data      interface{} // 成功响应 {code, msg, data} 里data的类型，为nil表示没有data
+	Paginated bool        // 成功响应带 pagination
+	Response  interface{} // 成功响应的完整类型，不使用 {code, msg, data} 格式的接口才需要
+	Produces  []string    // 其它响应格式，如RSS、纯文本，响应体按字符串描述
+	Raw       bool        // 成功时只返回Produces里的格式，没有JSON
+	Cacheable bool        // 支持条件请求，可能返回304
+}
+
+// LoginResponse 登录接口的响应，令牌直接放在顶层而不是data里
+type LoginResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	TokenPair
+}
+
+var (
+	idParam   = openapi3.NewIntegerSchema().WithMin(1)
+	feedParam = apiParam{Name: "format", Schema: openapi3.NewStringSchema().WithEnum(FeedRSS, FeedAtom), Desc: "rss(默认) / atom"}
+	feedTypes = []string{"application/rss+xml", "application/atom+xml"}
+)
+
+// apiDocs 所有接口的文档，key和限流配置一样是 "方法 路由"
+// 新增路由时在这里登记，没有登记的 /api 路由会被 openapi_test.go 发现
+var apiDocs = map[string]apiDoc{
+	// ---------------------- 健康检查 ----------------------
+	"GET /healthz": {Summary: "存活探针", Tag: "health"},
+	"GET /readyz":  {Summary: "就绪探针：数据库能连上并且表结构已迁移", Tag: "health"},
+
+	// ---------------------- 订阅 ----------------------
+	"GET /feed.xml": {Summary: "全站RSS订阅", Tag: "feed", Produces: feedTypes[:1], Raw: true, Cacheable: true},
+	"GET /atom.xml": {Summary: "全站Atom订阅", Tag: "feed", Produces: feedTypes[1:], Raw: true, Cacheable: true},
+	"GET /api/users/:id/feed": {Summary: "单个作者的RSS/Atom订阅", Tag: "feed", Auth: authOptional,
+		Params: []apiParam{feedParam}, Produces: feedTypes, Raw: true, Cacheable: true},
+
+	// ---------------------- 用户和令牌 ----------------------
+	"POST /api/register":      {Summary: "用户注册", Tag: "auth", Auth: authOptional, Body: RegisterRequest{}},
+	"POST /api/login":         {Summary: "用户登录", Tag: "auth", Auth: authOptional, Body: LoginRequest{}, Response: LoginResponse{}},
+	"POST /api/token/refresh": {Summary: "刷新令牌", Tag: "auth", Auth: authOptional, Body: refreshRequest{}, Data: TokenPair{}},
+	"POST /api/logout":        {Summary: "登出，吊销当前令牌", Tag: "auth", Auth: authRequired},
+
+	// ---------------------- 个人访问令牌 ----------------------
+	"POST /api/access-tokens": {Summary: "创建个人访问令牌，令牌只在这里返回一次", Tag: "auth", Auth: authRequired,
+		Body: PersonalTokenRequest{}, Data: PersonalTokenCreated{}},
+	"GET /api/access-tokens":        {Summary: "我的个人访问令牌（没有吊销的）", Tag: "auth", Auth: authRequired, Data: []PersonalTokenView{}},
+	"DELETE /api/access-tokens/:id": {Summary: "吊销个人访问令牌", Tag: "auth", Auth: authRequired},
+
+	// ---------------------- 邮箱验证和找回密码 ----------------------
+	"POST /api/email/verification": {Summary: "重新发送验证邮件，之前的链接作废", Tag: "auth", Auth: authRequired},
+	"POST /api/email/verify":       {Summary: "凭邮件里的令牌验证邮箱", Tag: "auth", Auth: authOptional, Body: EmailVerifyRequest{}},
+	"POST /api/password/forgot": {Summary: "申请重置密码，不管邮箱是否注册都返回成功", Tag: "auth", Auth: authOptional,
+		Body: ForgotPasswordRequest{}},
+	"POST /api/password/reset": {Summary: "凭邮件里的令牌设置新密码，所有设备需要重新登录", Tag: "auth", Auth: authOptional,
+		Body: ResetPasswordRequest{}},
+
+	// ---------------------- 文章 ----------------------
+	"GET /api/posts": {Summary: "文章列表（分页/过滤/排序）", Tag: "posts", Auth: authOptional,
+		Query: PostQuery{}, Data: []PostView{}, Paginated: true},
+	"GET /api/posts/:id":    {Summary: "文章详情，带渲染后的HTML和目录", Tag: "posts", Auth: authOptional, Data: PostDetail{}},
+	"POST /api/posts":       {Summary: "创建文章", Tag: "posts", Auth: authRequired, Body: PostRequest{}, Data: PostView{}},
+	"PUT /api/posts/:id":    {Summary: "更新文章", Tag: "posts", Auth: authRequired, Body: PostRequest{}, Data: PostView{}},
+	"DELETE /api/posts/:id": {Summary: "删除文章", Tag: "posts", Auth: authRequired},
+	"POST /api/posts/:id/publish": {Summary: "发布文章，publish_at在未来时改为定时发布", Tag: "posts", Auth: authRequired,
+		Body: publishRequest{}, OptionalBody: true, Data: PostView{}},
+	"POST /api/posts/:id/unpublish": {Summary: "撤回为草稿", Tag: "posts", Auth: authRequired, Data: PostView{}},
+
+	// ---------------------- 修订历史 ----------------------
+	"GET /api/posts/:id/revisions": {Summary: "文章修订历史（不含正文）", Tag: "revisions", Auth: authOptional, Data: []RevisionView{}},
+	"GET /api/posts/:id/revisions/:rev": {Summary: "某个版本的完整内容", Tag: "revisions", Auth: authOptional,
+		Data: RevisionView{}},
+	"GET /api/posts/:id/revisions/diff": {Summary: "对比两个版本", Tag: "revisions", Auth: authOptional,
+		Params: []apiParam{
+			{Name: "from", Schema: idParam, Desc: "旧版本号", Required: true},
+			{Name: "to", Schema: idParam, Desc: "新版本号", Required: true},
+			{Name: "format", Schema: openapi3.NewStringSchema().WithEnum("raw"), Desc: "raw 时直接返回diff文本"},
+		},
+		Data: RevisionDiff{}, Produces: []string{"text/plain"}},
+	"POST /api/posts/:id/revisions/:rev/restore": {Summary: "回滚到某个版本", Tag: "revisions", Auth: authRequired, Data: PostView{}},
+
+	// ---------------------- 评论 ----------------------
+	"GET /api/posts/:id/comments": {Summary: "文章评论（回复树）", Tag: "comments", Auth: authOptional,
+		Params: []apiParam{{Name: "flat", Schema: openapi3.NewBoolSchema(), Desc: "true 时按深度优先顺序平铺返回"}},
+		Data:   []*CommentNode{}},
+	"POST /api/comments":       {Summary: "发表评论", Tag: "comments", Auth: authRequired, Body: CreateCommentRequest{}, Data: CommentNode{}},
+	"PUT /api/comments/:id":    {Summary: "修改评论", Tag: "comments", Auth: authRequired, Body: commentUpdateRequest{}, Data: CommentNode{}},
+	"DELETE /api/comments/:id": {Summary: "删除评论", Tag: "comments", Auth: authRequired},
+
+	// ---------------------- 关注 ----------------------
+	"POST /api/users/:id/follow":   {Summary: "关注用户，已经关注过时同样成功", Tag: "follows", Auth: authRequired},
+	"DELETE /api/users/:id/follow": {Summary: "取消关注", Tag: "follows", Auth: authRequired},
+	"GET /api/users/:id/followers": {Summary: "粉丝列表，按关注时间倒序", Tag: "follows", Auth: authOptional,
+		Query: FollowQuery{}, Data: []FollowView{}, Paginated: true},
+	"GET /api/users/:id/following": {Summary: "关注列表，按关注时间倒序", Tag: "follows", Auth: authOptional,
+		Query: FollowQuery{}, Data: []FollowView{}, Paginated: true},
+	"GET /api/feed": {Summary: "关注动态：关注的作者已发布的文章，按创建时间倒序", Tag: "follows", Auth: authRequired,
+		Query: FeedQuery{}, Data: []PostView{}, Paginated: true},
+
+	// ---------------------- 通知 ----------------------
+	"GET /api/notifications": {Summary: "我的通知，带未读数量", Tag: "notifications", Auth: authRequired,
+		Query: NotificationQuery{}, Data: NotificationList{}, Paginated: true},
+	"POST /api/notifications/:id/read": {Summary: "标记一条通知为已读", Tag: "notifications", Auth: authRequired},
+	"POST /api/notifications/read-all": {Summary: "全部标记为已读", Tag: "notifications", Auth: authRequired},
+	"GET /api/notifications/preferences": {Summary: "通知偏好：每个类别是否屏蔽", Tag: "notifications", Auth: authRequired,
+		Data: []NotificationPreferenceView{}},
+	"PUT /api/notifications/preferences": {Summary: "修改通知偏好，muted是要屏蔽的全部类别", Tag: "notifications", Auth: authRequired,
+		Body: NotificationPreferencesRequest{}, Data: []NotificationPreferenceView{}},
+
+	// ---------------------- 表态 ----------------------
+	"POST /api/posts/:id/reactions": {Summary: "给文章表态，已经表过同样的态时取消", Tag: "reactions", Auth: authRequired,
+		Body: ReactionRequest{}, Data: ReactionResult{}},
+	"POST /api/comments/:id/reactions": {Summary: "给评论表态，已经表过同样的态时取消", Tag: "reactions", Auth: authRequired,
+		Body: ReactionRequest{}, Data: ReactionResult{}},
+
+	// ---------------------- 搜索、标签和分类 ----------------------
+	"GET /api/search": {Summary: "全文搜索文章和评论", Tag: "search", Auth: authOptional,
+		Query: SearchQuery{}, Data: []SearchResult{}, Paginated: true},
+	"GET /api/tags":       {Summary: "标签列表（带文章数）", Tag: "taxonomy", Auth: authOptional, Data: []TagCount{}},
+	"GET /api/categories": {Summary: "分类列表（带文章数）", Tag: "taxonomy", Auth: authOptional, Data: []CategoryCount{}},
+
+	// ---------------------- 文件上传 ----------------------
+	"POST /api/uploads": {Summary: "上传图片/附件", Tag: "uploads", Auth: authRequired,
+		Form: []apiParam{
+			{Name: "file", Schema: openapi3.NewStringSchema().WithFormat("binary"), Required: true},
+			{Name: "post_id", Schema: idParam, Desc: "关联到自己的文章"},
+		},
+		Data: Attachment{}},
+	"GET /api/posts/:id/attachments": {Summary: "文章的附件", Tag: "uploads", Auth: authOptional, Data: []Attachment{}},
+	"DELETE /api/attachments/:id":    {Summary: "删除附件", Tag: "uploads", Auth: authRequired},
+
+	// ---------------------- 管理 ----------------------
+	"PUT /api/admin/users/:id/role": {Summary: "修改用户角色（需要 user:manage_roles 权限）", Tag: "admin", Auth: authRequired,
+		Body: roleRequest{}},
+	"GET /api/admin/audit-logs": {Summary: "审计日志（需要 audit:view 权限）", Tag: "admin", Auth: authRequired,
+		Query: AuditQuery{}, Data: []AuditLog{}, Paginated: true},
+}
+
+// openAPIPath 把gin的路由写法转成OpenAPI的：/posts/:id -> /posts/{id}
+func openAPIPath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// handlerName 从gin记录的handler函数名里取出方法名，作为operationId，如 main.(*Server).Login-fm -> Login
+func handlerName(name string) string {
+	return strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+}
+
+// buildOpenAPI 按路由表生成接口文档，返回的undocumented是没有在apiDocs里登记的路由
+func buildOpenAPI(routes gin.RoutesInfo) (doc *openapi3.T, undocumented []string) {
+	b := &schemaBuilder{schemas: openapi3.Schemas{}}
+	doc = &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "blog-server API",
+			Version: "1.0.0",
+			Description: "个人博客系统的REST接口。需要登录的接口在请求头带 Authorization: Bearer <token>，token可以是登录获得的JWT，" +
+				"也可以是 blog_pat_ 开头的个人访问令牌（每个接口需要的scope见接口说明）；错误响应的格式见 ErrorResponse。",
+		},
+		Paths: openapi3.Paths{},
+		Components: openapi3.Components{
+			Schemas: b.schemas,
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"bearerAuth": &openapi3.SecuritySchemeRef{Value: openapi3.NewJWTSecurityScheme()},
+			},
+		},
+	}
+	errorRef := b.ref(reflect.TypeOf(ErrorResponse{}))
+
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		d, ok := apiDocs[key]
+		if !ok {
+			undocumented = append(undocumented, key)
+			continue
+		}
+		op := openapi3.NewOperation()
+		op.OperationID = handlerName(route.Handler)
+		op.Summary = d.Summary
+		op.Tags = []string{d.Tag}
+
+		switch d.Auth {
+		case authOptional:
+			op.Security = openapi3.NewSecurityRequirements().
+				With(openapi3.NewSecurityRequirement()).
+				With(openapi3.NewSecurityRequirement().Authenticate("bearerAuth"))
+		case authRequired:
+			op.Security = openapi3.NewSecurityRequirements().With(openapi3.NewSecurityRequirement().Authenticate("bearerAuth"))
+		}
+		if d.Auth != authNone {
+			if scope := requiredScope(route.Method, route.Path); scope != "" {
+				op.Description = "使用个人访问令牌时需要 " + scope + " 权限"
+			} else if d.Auth == authRequired {
+				op.Description = "不接受个人访问令牌，只能用登录获得的token"
+			}
+		}
+
+		// 路径参数都是数字ID
+		for _, seg := range strings.Split(route.Path, "/") {
+			if strings.HasPrefix(seg, ":") {
+				op.AddParameter(openapi3.NewPathParameter(seg[1:]).WithSchema(idParam))
+			}
+		}
+		if d.Query != nil {
+			for _, p := range queryParams(reflect.TypeOf(d.Query)) {
+				op.AddParameter(p)
+			}
+		}
+		for _, p := range d.Params {
+			op.AddParameter(openapi3.NewQueryParameter(p.Name).WithDescription(p.Desc).WithRequired(p.Required).WithSchema(p.Schema))
+		}
+
+		switch {
+		case d.Body != nil:
+			rb := &schemaBuilder{request: true}
+			body := openapi3.NewRequestBody().WithRequired(!d.OptionalBody).WithJSONSchemaRef(rb.ref(reflect.TypeOf(d.Body)))
+			op.RequestBody = &openapi3.RequestBodyRef{Value: body}
+		case d.Form != nil:
+			form := openapi3.NewObjectSchema()
+			for _, p := range d.Form {
+				form.Properties[p.Name] = p.Schema.NewRef()
+				if p.Required {
+					form.Required = append(form.Required, p.Name)
+				}
+			}
+			op.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithFormDataSchema(form)}
+		}
+
+		content := openapi3.Content{}
+		if !d.Raw {
+			content = openapi3.NewContentWithJSONSchemaRef(b.response(&d))
+		}
+		for _, ct := range d.Produces {
+			content[ct] = openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema())
+		}
+		op.Responses = openapi3.Responses{
+			"200":     &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("成功").WithContent(content)},
+			"default": &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("错误").WithJSONSchemaRef(errorRef)},
+		}
+		if d.Cacheable {
+			op.AddResponse(http.StatusNotModified, openapi3.NewResponse().WithDescription("内容没有变化（条件请求）"))
+		}
+
+		path := openAPIPath(route.Path)
+		item := doc.Paths[path]
+		if item == nil {
+			item = &openapi3.PathItem{}
+			doc.Paths[path] = item
+		}
+		item.SetOperation(route.Method, op)
+	}
+	sort.Strings(undocumented)
+	return doc, undocumented
+}
+
+// queryParams 按form tag把查询参数结构体转成参数列表，binding:"required" 的是必填参数
+func queryParams(t reflect.Type) []*openapi3.Parameter {
+	var params []*openapi3.Parameter
+	b := &schemaBuilder{request: true}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("form")
+		if name == "" || name == "-" {
+			continue
+		}
+		params = append(params, openapi3.NewQueryParameter(name).WithSchema(b.ref(f.Type).Value).WithRequired(hasRule(f, "required")))
+	}
+	return params
+}
+
+// hasRule 字段的binding tag里是否有某条规则
+func hasRule(f reflect.StructField, rule string) bool {
+	for _, r := range strings.Split(f.Tag.Get("binding"), ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------- Go类型 -> JSON Schema ----------------------
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// schemaBuilder 按 encoding/json 的规则把Go类型转换成JSON Schema
+// 响应：有名字的结构体放进 components/schemas 用 $ref 引用（评论树这种递归类型也能表示），
+// 没有omitempty的字段都是必有的，不允许出现文档里没有的字段
+// 请求：全部内联，binding:"required" 的字段才是必填的，多传的字段会被忽略
+type schemaBuilder struct {
+	schemas openapi3.Schemas
+	request bool
+}
+
+func (b *schemaBuilder) ref(t reflect.Type) *openapi3.SchemaRef {
+	switch t {
+	case timeType:
+		return openapi3.NewDateTimeSchema().NewRef()
+	case deletedAtType:
+		return openapi3.NewDateTimeSchema().WithNullable().NewRef()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(b.ref(t.Elem()))
+	case reflect.Struct:
+		if t.Name() == "" || b.request {
+			return b.object(t).NewRef()
+		}
+		if ref, ok := b.schemas[t.Name()]; ok {
+			return openapi3.NewSchemaRef("#/components/schemas/"+t.Name(), ref.Value)
+		}
+		// 先登记再填字段，结构体引用自己时直接拿到这个引用
+		s := openapi3.NewObjectSchema()
+		b.schemas[t.Name()] = s.NewRef()
+		b.fill(s, t)
+		return openapi3.NewSchemaRef("#/components/schemas/"+t.Name(), s)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return openapi3.NewBytesSchema().NewRef()
+		}
+		s := openapi3.NewArraySchema()
+		s.Items = b.ref(t.Elem())
+		s.Nullable = t.Kind() == reflect.Slice // nil切片序列化为null
+		return s.NewRef()
+	case reflect.Map:
+		s := openapi3.NewObjectSchema()
+		s.AdditionalProperties = b.ref(t.Elem())
+		s.Nullable = true
+		return s.NewRef()
+	case reflect.Interface:
+		return (&openapi3.Schema{Nullable: true}).NewRef()
+	case reflect.Bool:
+		return openapi3.NewBoolSchema().NewRef()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return openapi3.NewIntegerSchema().NewRef()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return openapi3.NewIntegerSchema().WithMin(0).NewRef()
+	case reflect.Float32, reflect.Float64:
+		return openapi3.NewFloat64Schema().NewRef()
+	case reflect.String:
+		return openapi3.NewStringSchema().NewRef()
+	}
+	return (&openapi3.Schema{}).NewRef()
+}
+
+// nullable 允许为null；$ref 旁边不能写其它属性，所以引用要包一层allOf
+func nullable(ref *openapi3.SchemaRef) *openapi3.SchemaRef {
+	if ref.Ref != "" {
+		return (&openapi3.Schema{Nullable: true, AllOf: openapi3.SchemaRefs{ref}}).NewRef()
+	}
+	s := *ref.Value
+	s.Nullable = true
+	return s.NewRef()
+}
+
+func (b *schemaBuilder) object(t reflect.Type) *openapi3.Schema {
+	s := openapi3.NewObjectSchema()
+	b.fill(s, t)
+	return s
+}
+
+// fill 把结构体的字段加到s上：json tag改名、"-"跳过、没有json名字的匿名结构体字段（如gorm.Model）展开到外层
+func (b *schemaBuilder) fill(s *openapi3.Schema, t reflect.Type) {
+	if !b.request {
+		s.AdditionalPropertiesAllowed = openapi3.BoolPtr(false)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.fill(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = b.ref(f.Type)
+		required := !strings.Contains(opts, "omitempty")
+		if b.request {
+			required = hasRule(f, "required")
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// response 成功响应的schema：默认是 {code, msg, data, pagination} 信封
+func (b *schemaBuilder) response(d *apiDoc) *openapi3.SchemaRef {
+	if d.Response != nil {
+		return b.ref(reflect.TypeOf(d.Response))
+	}
+	s := openapi3.NewObjectSchema().
+		WithProperty("code", openapi3.NewIntegerSchema()).
+		WithProperty("msg", openapi3.NewStringSchema())
+	s.Required = []string{"code", "msg"}
+	if d.Data != nil {
+		s.Properties["data"] = b.ref(reflect.TypeOf(d.Data))
+		s.Required = append(s.Required, "data")
+	}
+	if d.Paginated {
+		s.Properties["pagination"] = b.ref(reflect.TypeOf(Pagination{}))
+		s.Required = append(s.Required, "pagination")
+	}
+	s.AdditionalPropertiesAllowed = openapi3.BoolPtr(false)
+	return s.NewRef()
+}
+
+// ---------------------- 文档接口 ----------------------
+
+// buildSpec 注册完所有路由后调用，生成接口文档
+func (s *Server) buildSpec(r *gin.Engine) error {
+	doc, _ := buildOpenAPI(r.Routes())
+	if err := doc.Validate(context.Background()); err != nil {
+		return fmt.Errorf("接口文档不合法: %w", err)
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	s.spec, s.specJSON = doc, raw
+	return nil
+}
+
+// OpenAPISpec 接口文档 GET /openapi.json 【无需登录】
+func (s *Server) OpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", s.specJSON)
+}
+
+// APIDocs Swagger UI GET /docs/ 【无需登录】
+// 页面是下面的swaggerUIPage，js和css来自 swaggo/files 内嵌的 Swagger UI，不依赖外网
+func (s *Server) APIDocs(c *gin.Context) {
+	switch p := c.Param("filepath"); p {
+	case "/", "/index.html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+	default:
+		c.FileFromFS(p, swaggerFiles.HTTP)
+	}
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+  <meta charset="UTF-8">
+  <title>blog-server API</title>
+  <link rel="stylesheet" href="./swagger-ui.css">
+  <link rel="icon" type="image/png" href="./favicon-32x32.png" sizes="32x32">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="./swagger-ui-bundle.js"></script>
+  <script src="./swagger-ui-standalone-preset.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({
+      url: "/openapi.json",
+      dom_id: "#swagger-ui",
+      deepLinking: true,
+      persistAuthorization: true,
+      presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+      layout: "StandaloneLayout"
+    });
+  </script>
+</body>
+</html>
+`
+
+// ---------------------- 按文档校验请求和响应 ----------------------
+
+// bodyRecorder 写响应的同时留一份，请求结束后用来校验
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(str string) (int, error) {
+	w.body.WriteString(str)
+	return w.ResponseWriter.WriteString(str)
+}
+
+// OpenAPIValidator Gin中间件：按接口文档校验请求和响应，不一致时记日志，见配置 openapi.validate
+// 请求体和响应体都要完整缓存一份，只用于测试和联调环境；必须放在ErrorMiddleware外层，才能看到错误响应
+func (s *Server) OpenAPIValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var reqBody []byte
+		if c.Request.Body != nil {
+			var err error
+			if reqBody, err = io.ReadAll(c.Request.Body); err != nil {
+				fail(c, errMalformedBody)
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(reqBody))
+		}
+		w := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		if err := s.checkSpec(c, reqBody, w.body.Bytes()); err != nil {
+			log.Warnf("%s %s 与接口文档不一致: %v", c.Request.Method, c.Request.URL.Path, err)
+			if s.specViolation != nil {
+				s.specViolation(c, err)
+			}
+		}
+	}
+}
+
+func (s *Server) checkSpec(c *gin.Context, reqBody, respBody []byte) error {
+	if s.spec == nil || c.FullPath() == "" {
+		return nil // 没有匹配到路由
+	}
+	path := openAPIPath(c.FullPath())
+	item := s.spec.Paths[path]
+	if item == nil || item.GetOperation(c.Request.Method) == nil {
+		return nil // 没有写进文档的路由，如静态文件
+	}
+	ctx := context.Background()
+	params := make(map[string]string, len(c.Params))
+	for _, p := range c.Params {
+		params[p.Key] = p.Value
+	}
+	req := c.Request.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: params,
+		Route: &routers.Route{Spec: s.spec, Path: path, PathItem: item,
+			Method: c.Request.Method, Operation: item.GetOperation(c.Request.Method)},
+		Options: &openapi3filter.Options{
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc, // 登录由AuthMiddleware负责
+			ExcludeRequestBody: strings.HasPrefix(c.ContentType(), "multipart/"),
+		},
+	}
+
+	// 请求只在成功时校验：参数不对返回400本来就是预期的行为
+	status := c.Writer.Status()
+	if status < http.StatusMultipleChoices {
+		if err := openapi3filter.ValidateRequest(ctx, input); err != nil {
+			return fmt.Errorf("请求: %w", err)
+		}
+	}
+
+	// 响应的状态码都要在文档里，非JSON的响应（订阅、diff文本）只检查状态码
+	resp := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 status,
+		Header:                 c.Writer.Header(),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			ExcludeResponseBody:   !strings.HasPrefix(c.Writer.Header().Get("Content-Type"), gin.MIMEJSON),
+		},
+	}
+	resp.SetBodyBytes(respBody)
+	if err := openapi3filter.ValidateResponse(ctx, resp); err != nil {
+		return fmt.Errorf("响应: %w", err)
+	}
+	return nil
+}
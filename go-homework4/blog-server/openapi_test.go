@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+// 接口文档测试：用内存仓储启动服务并打开 openapi.validate，把主要接口都调一遍，
+// 任何请求或响应和 /openapi.json 不一致都判失败
+
+// specServer 打开文档校验的测试服务，违反文档时直接让测试失败
+func specServer(t *testing.T) (*Server, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := defaultConfig()
+	cfg.OpenAPI.Validate = true
+	cfg.RateLimit.Enabled = false
+
+	repos := NewMemoryRepositories()
+	storage, err := NewLocalStorage(t.TempDir(), "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := &memorySearcher{idx: newInvertedIndex(), posts: repos.Posts, comments: repos.Comments}
+	mailer := NewLogMailer(t.TempDir() + "/mail.log") // 邮件写到文件里，测试从这里取验证链接
+	s := NewServer(cfg, repos, search, storage, NewMemoryRateLimitStore(), mailer)
+	s.specViolation = func(c *gin.Context, err error) {
+		t.Errorf("%s %s 与接口文档不一致: %v", c.Request.Method, c.Request.URL, err)
+	}
+	return s, s.Router()
+}
+
+// specClient 发请求并检查状态码，返回解析后的JSON响应
+type specClient struct {
+	t *testing.T
+	r http.Handler
+}
+
+func (sc specClient) send(req *http.Request, token string, want int) *httptest.ResponseRecorder {
+	sc.t.Helper()
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	sc.r.ServeHTTP(w, req)
+	if w.Code != want {
+		sc.t.Fatalf("%s %s 状态码 %d，期望 %d：%s", req.Method, req.URL, w.Code, want, w.Body.String())
+	}
+	// 任何响应里都不能出现密码字段，哪怕是空的；接口文档本身会描述登录和注册的请求体，不算
+	var body interface{}
+	if req.URL.Path != "/openapi.json" && json.Unmarshal(w.Body.Bytes(), &body) == nil {
+		if path := findPasswordField(body, ""); path != "" {
+			sc.t.Errorf("%s %s 的响应里有密码字段 %s", req.Method, req.URL, path)
+		}
+	}
+	return w
+}
+
+// findPasswordField 在JSON里找名字带password的字段，返回它的路径
+func findPasswordField(v interface{}, path string) string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if strings.Contains(strings.ToLower(key), "password") {
+				return path + "/" + key
+			}
+			if found := findPasswordField(child, path+"/"+key); found != "" {
+				return found
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if found := findPasswordField(child, fmt.Sprint(path, "/", i)); found != "" {
+				return found
+			}
+		}
+	}
+	return ""
+}
+
+func (sc specClient) call(method, url, token string, body interface{}, want int) map[string]interface{} {
+	sc.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			sc.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, url, &buf)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(sc.send(req, token, want).Body.Bytes(), &resp); err != nil {
+		sc.t.Fatalf("%s %s 响应不是JSON: %v", method, url, err)
+	}
+	return resp
+}
+
+// id 从响应的data里取出ID
+func (sc specClient) id(resp map[string]interface{}, key string) uint {
+	sc.t.Helper()
+	data, _ := resp["data"].(map[string]interface{})
+	id, ok := data[key].(float64)
+	if !ok {
+		sc.t.Fatalf("响应里没有 data.%s: %v", key, resp)
+	}
+	return uint(id)
+}
+
+// errorCode 取出错误响应里稳定的错误码
+func errorCode(resp map[string]interface{}) string {
+	detail, _ := resp["error"].(map[string]interface{})
+	code, _ := detail["code"].(string)
+	return code
+}
+
+// mailToken 从测试邮件文件里取出最近一封邮件中 path 链接带的token
+func mailToken(t *testing.T, s *Server, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(s.mailer.(*LogMailer).path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := regexp.MustCompile(regexp.QuoteMeta(path)+`\?token=(\S+)`).FindAllStringSubmatch(string(data), -1)
+	if len(matches) == 0 {
+		t.Fatalf("邮件里没有 %s 链接", path)
+	}
+	token, err := url.QueryUnescape(matches[len(matches)-1][1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func (sc specClient) login(name string) string {
+	sc.t.Helper()
+	sc.call("POST", "/api/register", "", gin.H{"username": name, "password": "secret", "email": name + "@example.com"}, 200)
+	resp := sc.call("POST", "/api/login", "", gin.H{"username": name, "password": "secret"}, 200)
+	return resp["token"].(string)
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	s, r := specServer(t)
+	if s.spec == nil {
+		t.Fatal("没有生成接口文档")
+	}
+
+	// 所有 /api 接口都要写进文档
+	_, undocumented := buildOpenAPI(r.Routes())
+	for _, route := range undocumented {
+		if strings.Contains(route, " /api/") {
+			t.Errorf("路由 %s 没有写进接口文档，请在 apiDocs 里登记", route)
+		}
+	}
+
+	sc := specClient{t, r}
+	w := sc.send(httptest.NewRequest("GET", "/openapi.json", nil), "", 200)
+	doc, err := openapi3.NewLoader().LoadFromData(w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("/openapi.json 解析失败: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("/openapi.json 不合法: %v", err)
+	}
+	if doc.Paths["/api/posts/{id}"].Get == nil {
+		t.Error("文档里没有 GET /api/posts/{id}")
+	}
+
+	w = sc.send(httptest.NewRequest("GET", "/docs/", nil), "", 200)
+	if !strings.Contains(w.Body.String(), "/openapi.json") {
+		t.Error("/docs/ 页面没有加载 /openapi.json")
+	}
+	sc.send(httptest.NewRequest("GET", "/docs/swagger-ui-bundle.js", nil), "", 200)
+}
+
+// TestSpecHasNoPasswordInResponses 文档里任何接口的响应结构都不能带密码字段，覆盖测试没有调到的接口
+func TestSpecHasNoPasswordInResponses(t *testing.T) {
+	s, _ := specServer(t)
+	seen := map[*openapi3.Schema]bool{}
+	var walk func(ref *openapi3.SchemaRef, path string)
+	walk = func(ref *openapi3.SchemaRef, path string) {
+		if ref == nil || ref.Value == nil || seen[ref.Value] {
+			return
+		}
+		seen[ref.Value] = true
+		for name, prop := range ref.Value.Properties {
+			if strings.Contains(strings.ToLower(name), "password") {
+				t.Errorf("%s 里有密码字段 %s", path, name)
+			}
+			walk(prop, path+"."+name)
+		}
+		walk(ref.Value.Items, path+"[]")
+		walk(ref.Value.AdditionalProperties, path+"{}")
+		for _, sub := range ref.Value.AllOf {
+			walk(sub, path)
+		}
+	}
+	for path, item := range s.spec.Paths {
+		for method, op := range item.Operations() {
+			for status, resp := range op.Responses {
+				for ct, media := range resp.Value.Content {
+					walk(media.Schema, fmt.Sprintf("%s %s %s %s", method, path, status, ct))
+				}
+			}
+		}
+	}
+}
+
+// TestResponsesMatchSpec 把主要接口的成功和常见错误响应都调一遍，检查和文档一致
+// 各功能的行为在各自的测试文件里，如 notification_test.go、access_token_test.go，这里不检查响应内容
+func TestResponsesMatchSpec(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	bob := sc.login("bob")
+	sc.login("root")
+	if err := bootstrapAdmins(context.Background(), s.Users, []string{"root"}); err != nil {
+		t.Fatal(err)
+	}
+	root := sc.call("POST", "/api/login", "", gin.H{"username": "root", "password": "secret"}, 200)["token"].(string)
+
+	// 注册和令牌
+	sc.call("POST", "/api/register", "", gin.H{"username": "carol", "password": "123", "email": "bad"}, 400)
+	sc.call("POST", "/api/register", "", gin.H{"username": "alice", "password": "secret", "email": "a2@example.com"}, 409)
+	resp := sc.call("POST", "/api/login", "", gin.H{"username": "alice", "password": "secret"}, 200)
+	sc.call("POST", "/api/token/refresh", "", gin.H{"refresh_token": resp["refresh_token"]}, 200)
+	sc.call("POST", "/api/token/refresh", "", gin.H{"refresh_token": resp["refresh_token"]}, 401)
+	sc.call("POST", "/api/login", "", gin.H{"username": "alice", "password": "wrong"}, 401)
+
+	// 邮箱验证和找回密码
+	dave := sc.login("dave")
+	s.cfg.Accounts.RequireVerifiedEmail = true
+	sc.call("POST", "/api/comments", dave, gin.H{"post_id": "1"}, 403)
+	s.cfg.Accounts.RequireVerifiedEmail = false
+	sc.call("POST", "/api/email/verification", dave, nil, 200)
+	verify := mailToken(t, s, "/verify-email")
+	sc.call("POST", "/api/email/verify", "", gin.H{"token": verify}, 200)
+	sc.call("POST", "/api/email/verify", "", gin.H{"token": verify}, 400)
+	sc.call("POST", "/api/email/verification", dave, nil, 200)
+	sc.call("POST", "/api/password/forgot", "", gin.H{"email": "dave@example.com"}, 200)
+	sc.call("POST", "/api/password/forgot", "", gin.H{"email": "bad"}, 400)
+	reset := mailToken(t, s, "/reset-password")
+	sc.call("POST", "/api/password/reset", "", gin.H{"token": reset, "password": "123"}, 400)
+	sc.call("POST", "/api/password/reset", "", gin.H{"token": reset, "password": "newsecret"}, 200)
+
+	// 文章
+	post := sc.call("POST", "/api/posts", alice, gin.H{"title": "Hello", "content": "# Go\n\nhello world", "tags": []string{"Go", "Web"}, "category": "Tech"}, 200)
+	pid := sc.id(post, "id")
+	postID := fmt.Sprint(pid)
+	draft := fmt.Sprint(sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "Draft", "content": "wip", "status": StatusDraft}, 200), "id"))
+	sc.call("GET", "/api/posts?page_size=1&tag=go", "", nil, 200)
+	sc.call("GET", "/api/posts?status=draft", alice, nil, 200)
+	sc.call("GET", "/api/posts?sort_by=title", "", nil, 400)
+	sc.call("GET", "/api/posts/"+postID, "", nil, 200)
+	sc.call("GET", "/api/posts/"+postID, alice, nil, 200)
+	sc.call("GET", "/api/posts/"+draft, "", nil, 404)
+	sc.call("GET", "/api/posts/abc", "", nil, 400)
+	sc.call("PUT", "/api/posts/"+postID, alice, gin.H{"content": "# Go\n\nhello gopher", "tags": []string{}}, 200)
+	sc.call("PUT", "/api/posts/"+postID, bob, gin.H{"content": "hacked"}, 403)
+	sc.call("POST", "/api/posts", "", gin.H{"title": "x"}, 401)
+	sc.call("POST", "/api/posts/"+draft+"/publish", alice, nil, 200)
+	sc.call("POST", "/api/posts/"+draft+"/unpublish", alice, nil, 200)
+	sc.call("POST", "/api/posts/"+draft+"/publish", alice, gin.H{"publish_at": "2099-01-02T15:04:05+08:00"}, 200)
+
+	// 修订历史
+	sc.call("GET", "/api/posts/"+postID+"/revisions", "", nil, 200)
+	sc.call("GET", "/api/posts/"+postID+"/revisions/1", "", nil, 200)
+	sc.call("GET", "/api/posts/"+postID+"/revisions/diff?from=1&to=2", "", nil, 200)
+	sc.send(httptest.NewRequest("GET", "/api/posts/"+postID+"/revisions/diff?from=1&to=2&format=raw", nil), "", 200)
+	sc.call("POST", "/api/posts/"+postID+"/revisions/1/restore", alice, nil, 200)
+
+	// 评论
+	top := sc.id(sc.call("POST", "/api/comments", bob, gin.H{"post_id": sc.id(post, "id"), "content": "nice @root"}, 200), "id")
+	reply := sc.id(sc.call("POST", "/api/comments", alice, gin.H{"post_id": sc.id(post, "id"), "parent_id": top, "content": "thanks"}, 200), "id")
+	sc.call("POST", "/api/comments", bob, gin.H{"post_id": "1"}, 400)
+	sc.call("PUT", fmt.Sprint("/api/comments/", reply), alice, gin.H{"content": "thanks!"}, 200)
+
+	// 表态
+	sc.call("POST", "/api/posts/"+postID+"/reactions", bob, gin.H{"kind": "like"}, 200)
+	sc.call("POST", "/api/posts/"+postID+"/reactions", alice, gin.H{"kind": "meh"}, 400)
+	sc.call("POST", "/api/posts/"+draft+"/reactions", bob, gin.H{"kind": "like"}, 404)
+	sc.call("POST", fmt.Sprint("/api/comments/", reply, "/reactions"), bob, gin.H{"kind": "laugh"}, 200)
+	sc.call("GET", "/api/posts/"+postID+"/comments", bob, nil, 200)
+
+	// 通知
+	sc.call("GET", "/api/notifications", alice, nil, 200)
+	sc.call("GET", "/api/notifications?unread=true", root, nil, 200)
+	sc.call("PUT", "/api/notifications/preferences", root, gin.H{"muted": []string{NotifyMention}}, 200)
+	sc.call("PUT", "/api/notifications/preferences", root, gin.H{"muted": []string{"spam"}}, 400)
+	sc.call("GET", "/api/notifications/preferences", root, nil, 200)
+	sc.call("POST", "/api/notifications/1/read", alice, nil, 200) // bob的评论通知了文章作者alice
+	sc.call("POST", "/api/notifications/1/read", bob, nil, 404)
+	sc.call("POST", "/api/notifications/x/read", root, nil, 400)
+	sc.call("POST", "/api/notifications/read-all", alice, nil, 200)
+
+	sc.call("DELETE", fmt.Sprint("/api/comments/", top), alice, nil, 403)
+	sc.call("DELETE", fmt.Sprint("/api/comments/", top), bob, nil, 200)
+	sc.call("GET", "/api/posts/"+postID+"/comments", "", nil, 200)
+	sc.call("GET", "/api/posts/"+postID+"/comments?flat=true", "", nil, 200)
+
+	// 关注和关注动态
+	sc.call("POST", "/api/users/1/follow", bob, nil, 200)
+	sc.call("POST", "/api/users/2/follow", bob, nil, 400)
+	sc.call("POST", "/api/users/99/follow", bob, nil, 404)
+	sc.call("GET", "/api/users/1/followers", "", nil, 200)
+	sc.call("GET", "/api/users/2/following?page_size=1", "", nil, 200)
+	sc.call("GET", "/api/feed?page_size=1", bob, nil, 200)
+	sc.call("GET", "/api/feed", "", nil, 401)
+	sc.call("DELETE", "/api/users/1/follow", bob, nil, 200)
+
+	// 搜索、标签和分类
+	sc.call("GET", "/api/search?q=gopher", "", nil, 200)
+	sc.call("GET", "/api/search", "", nil, 400)
+	sc.call("GET", "/api/tags", "", nil, 200)
+	sc.call("GET", "/api/categories", "", nil, 200)
+
+	// 上传
+	uploaded := sc.upload(alice, testPNG(t), pid, 200)
+	sc.call("GET", "/api/posts/"+postID+"/attachments", "", nil, 200)
+	sc.call("DELETE", fmt.Sprint("/api/attachments/", sc.id(uploaded, "id")), alice, nil, 200)
+
+	// 订阅，非JSON的响应只检查状态码
+	w := sc.send(httptest.NewRequest("GET", "/feed.xml", nil), "", 200)
+	req := httptest.NewRequest("GET", "/feed.xml", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	sc.send(req, "", 304)
+	sc.send(httptest.NewRequest("GET", "/atom.xml", nil), "", 200)
+	sc.send(httptest.NewRequest("GET", "/api/users/1/feed?format=atom", nil), "", 200)
+	sc.call("GET", "/api/users/99/feed", "", nil, 404)
+
+	// 个人访问令牌
+	created := sc.call("POST", "/api/access-tokens", bob, gin.H{"name": "ci", "scopes": []string{"read", "write:comments"}}, 200)
+	pat := created["data"].(map[string]interface{})["token"].(string)
+	sc.call("POST", "/api/access-tokens", bob, gin.H{"name": "x", "scopes": []string{"admin"}}, 400)
+	sc.call("GET", "/api/notifications", pat, nil, 200)
+	sc.call("POST", "/api/posts", pat, gin.H{"title": "x", "content": "y"}, 403)
+	sc.call("GET", "/api/access-tokens", bob, nil, 200)
+	patID := fmt.Sprint("/api/access-tokens/", sc.id(created, "id"))
+	sc.call("DELETE", patID, alice, nil, 404)
+	sc.call("DELETE", patID, bob, nil, 200)
+	sc.call("GET", "/api/notifications", pat, nil, 401)
+
+	// 管理接口
+	sc.call("PUT", "/api/admin/users/2/role", root, gin.H{"role": RoleModerator}, 200)
+	sc.call("PUT", "/api/admin/users/2/role", alice, gin.H{"role": RoleAdmin}, 403)
+	sc.call("GET", "/api/admin/audit-logs?page_size=5", root, nil, 200)
+
+	// 其它错误
+	sc.call("GET", "/api/nothing", "", nil, 404)
+	sc.call("DELETE", "/api/posts/"+postID, bob, nil, 403)
+	sc.call("DELETE", "/api/posts/"+postID, alice, nil, 200)
+	sc.call("POST", "/api/logout", alice, nil, 200)
+	sc.call("GET", "/api/posts?status=draft", alice, nil, 400)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 文章状态：草稿 / 定时发布 / 已发布 / 已归档 ======================
+// 只有已发布的文章对所有人可见，其它状态只有作者本人（以及有编辑任意文章权限的版主/管理员）能看到
+// 定时发布的文章由进程内的后台调度器在 publish_at 到点后自动改为已发布
+
+const (
+	StatusDraft     = "draft"     // 草稿
+	StatusScheduled = "scheduled" // 定时发布，publish_at 到点后自动发布
+	StatusPublished = "published" // 已发布
+	StatusArchived  = "archived"  // 已归档，不再出现在列表里
+)
+
+func validStatus(status string) bool {
+	switch status {
+	case StatusDraft, StatusScheduled, StatusPublished, StatusArchived:
+		return true
+	}
+	return false
+}
+
+// decideStatus 根据请求里的状态和定时发布时间算出文章最终的状态
+// status为空时：publish_at 在未来就是定时发布，否则直接发布
+func decideStatus(status string, publishAt *time.Time, now time.Time) (string, *time.Time, error) {
+	switch status {
+	case "":
+		if publishAt != nil && publishAt.After(now) {
+			return StatusScheduled, publishAt, nil
+		}
+		return StatusPublished, nil, nil
+	case StatusScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return "", nil, invalidField("publish_at", "future", "定时发布需要传入一个未来的时间")
+		}
+		return StatusScheduled, publishAt, nil
+	case StatusDraft, StatusPublished, StatusArchived:
+		return status, nil, nil
+	}
+	return "", nil, invalidField("status", "oneof", "只支持 draft、scheduled、published 或 archived")
+}
+
+// applyStatus 把状态写到文章上；第一次发布时记录发布时间
+func applyStatus(post *Post, status string, publishAt *time.Time, now time.Time) {
+	post.Status = status
+	post.PublishAt = publishAt
+	if status == StatusPublished && post.PublishedAt == nil {
+		post.PublishedAt = &now
+	}
+}
+
+// canViewPost 当前用户能否看到这篇文章：已发布的所有人可见，其它状态只有作者和版主可见
+func canViewPost(c *gin.Context, post *Post) bool {
+	if post.Status == StatusPublished {
+		return true
+	}
+	userID := c.GetUint("userID")
+	return userID != 0 && (userID == post.UserID || can(c, PermEditAnyPost))
+}
+
+// publishRequest 发布文章的请求体，可以不传
+type publishRequest struct {
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// PublishPost 发布文章 POST /api/posts/:id/publish 【需要登录+只有作者或版主可操作】
+// body可选：{"publish_at":"2026-01-02T15:04:05+08:00"}，时间在未来则改为定时发布
+func (s *Server) PublishPost(c *gin.Context) {
+	var req publishRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, bindError(err))
+			return
+		}
+	}
+	s.changeStatus(c, "", req.PublishAt, "文章发布成功！")
+}
+
+// UnpublishPost 撤回文章 POST /api/posts/:id/unpublish 【需要登录+只有作者或版主可操作】
+// 已发布或定时发布的文章改回草稿
+func (s *Server) UnpublishPost(c *gin.Context) {
+	s.changeStatus(c, StatusDraft, nil, "文章已撤回为草稿")
+}
+
+// changeStatus 发布/撤回接口的公共逻辑
+func (s *Server) changeStatus(c *gin.Context, status string, publishAt *time.Time, msg string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, errInvalidPostID)
+		return
+	}
+
+	post, err := s.Posts.FindByID(c.Request.Context(), uint(id))
+	if err != nil || !canViewPost(c, post) {
+		fail(c, errPostNotFound)
+		return
+	}
+	ok, privileged := authorize(c, post.UserID, PermEditAnyPost)
+	if !ok {
+		fail(c, errPostForbidden)
+		return
+	}
+
+	now := time.Now()
+	status, publishAt, err = decideStatus(status, publishAt, now)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+	oldStatus := post.Status
+	applyStatus(post, status, publishAt, now)
+	if err := s.Posts.SetStatus(c.Request.Context(), post); err != nil {
+		log.Errorf("修改文章状态失败: %v", err)
+		fail(c, apperr.Internal("修改文章状态失败"))
+		return
+	}
+	if privileged {
+		s.audit(c, AuditPostUpdate, "post", post.ID, "状态:"+oldStatus+" -> "+post.Status)
+	}
+	log.Infof("用户ID:%d 修改文章状态成功，文章ID:%d，%s -> %s", c.GetUint("userID"), post.ID, oldStatus, post.Status)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": msg, "data": s.viewPost(c, post)})
+}
+
+// ---------------------- 定时发布调度器 ----------------------
+
+// StartScheduler 在后台按固定间隔把到点的定时发布文章改为已发布，ctx取消后退出
+// 返回的channel在调度器退出后关闭，方便关闭服务时等待
+func StartScheduler(ctx context.Context, posts PostRepository, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			publishDue(ctx, posts)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+func publishDue(ctx context.Context, posts PostRepository) {
+	n, err := posts.PublishDue(ctx, time.Now())
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Errorf("定时发布文章失败: %v", err)
+		}
+		return
+	}
+	if n > 0 {
+		log.Infof("定时发布了 %d 篇文章", n)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDecideStatus(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	cases := []struct {
+		name      string
+		status    string
+		publishAt *time.Time
+		want      string
+		wantAt    *time.Time
+		badField  string // 不为空时期望400，并且指出这个字段
+	}{
+		{"默认直接发布", "", nil, StatusPublished, nil, ""},
+		{"未来时间定时发布", "", &future, StatusScheduled, &future, ""},
+		{"过去的时间直接发布", "", &past, StatusPublished, nil, ""},
+		{"定时发布", StatusScheduled, &future, StatusScheduled, &future, ""},
+		{"定时发布没有时间", StatusScheduled, nil, "", nil, "publish_at"},
+		{"定时发布过去的时间", StatusScheduled, &past, "", nil, "publish_at"},
+		{"定时发布当前时间", StatusScheduled, &now, "", nil, "publish_at"},
+		{"草稿忽略时间", StatusDraft, &future, StatusDraft, nil, ""},
+		{"归档", StatusArchived, nil, StatusArchived, nil, ""},
+		{"未知状态", "deleted", nil, "", nil, "status"},
+	}
+	for _, tc := range cases {
+		status, at, err := decideStatus(tc.status, tc.publishAt, now)
+		if tc.badField != "" {
+			e, ok := apperr.As(err)
+			if !ok || e.Status() != 400 || len(e.Fields) != 1 || e.Fields[0].Field != tc.badField {
+				t.Errorf("%s: 错误是 %#v，期望 %s 字段的400错误", tc.name, err, tc.badField)
+			}
+			continue
+		}
+		if err != nil || status != tc.want || !sameTime(at, tc.wantAt) {
+			t.Errorf("%s: 得到 %s %v %v，期望 %s %v", tc.name, status, at, err, tc.want, tc.wantAt)
+		}
+	}
+}
+
+// sameTime 两个可为空的时间是否相同
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// sqliteRepositories 临时SQLite数据库，执行完全部迁移
+func sqliteRepositories(t *testing.T) Repositories {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.DSN = t.TempDir() + "/blog.db"
+	db, err := openDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	return NewGormRepositories(db)
+}
+
+func TestPublishDue(t *testing.T) {
+	for name, repos := range map[string]Repositories{"memory": NewMemoryRepositories(), "sqlite": sqliteRepositories(t)} {
+		t.Run(name, func(t *testing.T) { testPublishDue(t, repos) })
+	}
+}
+
+func testPublishDue(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+	user := User{Username: "alice", Password: "x", Email: "alice@example.com", Role: RoleUser}
+	if err := repos.Users.Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	create := func(status string, publishAt, publishedAt *time.Time) uint {
+		t.Helper()
+		p := Post{Title: status, Content: "内容", UserID: user.ID, Format: FormatMarkdown, Status: status, PublishAt: publishAt, PublishedAt: publishedAt}
+		if err := repos.Posts.Create(ctx, &p); err != nil {
+			t.Fatal(err)
+		}
+		return p.ID
+	}
+	due := create(StatusScheduled, at(-time.Minute), nil)
+	// 以前发布过、撤回后又定时发布的文章，发布时间保留第一次的
+	republished := create(StatusScheduled, at(-time.Second), at(-time.Hour))
+	later := create(StatusScheduled, at(time.Hour), nil)
+	draft := create(StatusDraft, nil, nil)
+
+	check := func(id uint, status string, publishedAt *time.Time) {
+		t.Helper()
+		p, err := repos.Posts.FindByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Status != status || !sameTime(p.PublishedAt, publishedAt) {
+			t.Errorf("文章 %s: 状态 %s 发布时间 %v，期望 %s %v", p.Title, p.Status, p.PublishedAt, status, publishedAt)
+		}
+		if status == StatusPublished && p.PublishAt != nil {
+			t.Errorf("文章 %s 发布后 publish_at 没有清空", p.Title)
+		}
+	}
+
+	if n, err := repos.Posts.PublishDue(ctx, now); err != nil || n != 2 {
+		t.Fatalf("发布了 %d 篇: %v，期望 2 篇", n, err)
+	}
+	check(due, StatusPublished, at(-time.Minute))
+	check(republished, StatusPublished, at(-time.Hour))
+	check(later, StatusScheduled, nil)
+	check(draft, StatusDraft, nil)
+
+	// 再执行一次不会重复发布，发布时间不变
+	if n, err := repos.Posts.PublishDue(ctx, now.Add(time.Minute)); err != nil || n != 0 {
+		t.Fatalf("重复执行发布了 %d 篇: %v", n, err)
+	}
+	check(due, StatusPublished, at(-time.Minute))
+
+	if n, err := repos.Posts.PublishDue(ctx, now.Add(time.Hour)); err != nil || n != 1 {
+		t.Fatalf("到点后发布了 %d 篇: %v，期望 1 篇", n, err)
+	}
+	check(later, StatusPublished, at(time.Hour))
+	check(draft, StatusDraft, nil)
+}
+
+func TestPostStatusVisibility(t *testing.T) {
+	_, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	bob := sc.login("bob")
+
+	// 定时发布的时间必须在未来
+	for _, publishAt := range []interface{}{nil, time.Now().Add(-time.Minute).Format(time.RFC3339)} {
+		resp := sc.call("POST", "/api/posts", alice, gin.H{"title": "定时", "content": "内容", "status": StatusScheduled, "publish_at": publishAt}, 400)
+		if fields := resp["error"].(map[string]interface{})["fields"].([]interface{}); fields[0].(map[string]interface{})["field"] != "publish_at" {
+			t.Errorf("publish_at=%v 的错误是 %v", publishAt, resp)
+		}
+	}
+
+	draft := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "草稿", "content": "内容", "status": StatusDraft}, 200), "id")
+	scheduled := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "定时", "content": "内容",
+		"publish_at": time.Now().Add(time.Hour).Format(time.RFC3339)}, 200), "id")
+	published := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "已发布", "content": "内容"}, 200), "id")
+
+	// 没发布的文章只有作者能看到，别人看到的是404
+	for _, id := range []uint{draft, scheduled} {
+		url := fmt.Sprint("/api/posts/", id)
+		sc.call("GET", url, "", nil, 404)
+		sc.call("GET", url, bob, nil, 404)
+		sc.call("GET", url, alice, nil, 200)
+	}
+	sc.call("GET", fmt.Sprint("/api/posts/", published), "", nil, 200)
+
+	// 列表里只有已发布的
+	for _, token := range []string{"", bob} {
+		list := sc.call("GET", "/api/posts", token, nil, 200)["data"].([]interface{})
+		if len(list) != 1 || list[0].(map[string]interface{})["id"] != float64(published) {
+			t.Errorf("列表是 %v，期望只有已发布的文章", list)
+		}
+	}
+}
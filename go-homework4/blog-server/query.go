@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ====================== 文章列表查询：分页、过滤、排序 ======================
+const (
+	DefaultPageSize = 10  // 默认每页条数
+	MaxPageSize     = 100 // 每页最大条数，防止一次拉取过多数据
+)
+
+// PostQuery GET /api/posts 支持的查询参数
+// 两种分页方式：page/page_size 页码分页；cursor 游标分页（传了cursor时忽略page）
+type PostQuery struct {
+	Page      int    `form:"page"`       // 页码，从1开始
+	PageSize  int    `form:"page_size"`  // 每页条数
+	Cursor    string `form:"cursor"`     // 游标，取自上一页响应的 next_cursor
+	AuthorID  uint   `form:"author_id"`  // 按作者ID过滤
+	Author    string `form:"author"`     // 按作者用户名过滤
+	StartDate string `form:"start_date"` // 创建时间起点，格式 2006-01-02 或 RFC3339
+	EndDate   string `form:"end_date"`   // 创建时间终点，格式同上；只传日期时包含当天
+	Keyword   string `form:"keyword"`    // 标题关键字，模糊匹配
+	Tag       string `form:"tag"`        // 按标签名称过滤
+	Category  string `form:"category"`   // 按分类名称过滤
+	Status    string `form:"status"`     // 文章状态，默认published；其它状态只返回当前登录用户自己的文章
+	SortBy    string `form:"sort_by"`    // 排序字段：created_at(默认) / updated_at
+	Order     string `form:"order"`      // 排序方向：desc(默认) / asc
+
+	start, end *time.Time // 解析后的时间范围
+	cursor     *postCursor
+	viewerID   uint // 当前登录用户ID，游客为0
+	followerID uint // 不为0时只返回这个用户关注的作者的文章，关注动态用，见 follow.go
+}
+
+// Pagination 列表接口响应中的分页信息
+type Pagination struct {
+	Page       int    `json:"page,omitempty"` // 游标分页时不返回页码
+	PageSize   int    `json:"page_size"`
+	Total      int64  `json:"total"`       // 满足过滤条件的总数
+	NextCursor string `json:"next_cursor"` // 下一页游标，为空表示没有更多数据
+}
+
+// postCursor 游标内容：排序字段的值 + 文章ID，保证排序值相同时翻页结果仍然确定
+// 同时记下签发时的排序方式，换了 sort_by 或 order 之后旧游标不能再用
+type postCursor struct {
+	Value  time.Time `json:"v"`
+	ID     uint      `json:"id"`
+	SortBy string    `json:"s"`
+	Order  string    `json:"o"`
+}
+
+// encode 游标序列化为URL安全的base64字符串，对客户端不透明
+func (pc postCursor) encode() string {
+	raw, _ := json.Marshal(pc)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*postCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	pc := new(postCursor)
+	if err := json.Unmarshal(raw, pc); err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
+
+// parseDate 解析日期参数，dateOnly表示传入的是不带时间的日期
+func parseDate(s string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, s)
+	return t, false, err
+}
+
+// Normalize 校验并补全查询参数，参数不合法时返回错误
+func (q *PostQuery) Normalize() error {
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+
+	switch q.SortBy {
+	case "":
+		q.SortBy = "created_at"
+	case "created_at", "updated_at":
+	default:
+		return invalidField("sort_by", "oneof", "只支持 created_at 或 updated_at")
+	}
+	switch strings.ToLower(q.Order) {
+	case "":
+		q.Order = "desc"
+	case "desc", "asc":
+		q.Order = strings.ToLower(q.Order)
+	default:
+		return invalidField("order", "oneof", "只支持 desc 或 asc")
+	}
+
+	if q.StartDate != "" {
+		t, _, err := parseDate(q.StartDate)
+		if err != nil {
+			return invalidField("start_date", "datetime", "格式错误")
+		}
+		q.start = &t
+	}
+	if q.EndDate != "" {
+		t, dateOnly, err := parseDate(q.EndDate)
+		if err != nil {
+			return invalidField("end_date", "datetime", "格式错误")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1) // 只传日期时包含当天全天
+		}
+		q.end = &t
+	}
+
+	switch q.Status {
+	case "":
+		q.Status = StatusPublished
+	case StatusPublished:
+	case StatusDraft, StatusScheduled, StatusArchived:
+		if q.viewerID == 0 {
+			return invalidField("status", "login", "查看草稿、定时发布或已归档的文章需要先登录")
+		}
+	default:
+		return invalidField("status", "oneof", "只支持 draft、scheduled、published 或 archived")
+	}
+
+	// 标签和分类按规范化后的名称匹配，"Go" 和 "go" 是同一个标签
+	q.Tag = normalizeName(q.Tag)
+	q.Category = normalizeName(q.Category)
+
+	if q.Cursor != "" {
+		pc, err := decodeCursor(q.Cursor)
+		if err != nil || pc.SortBy != q.SortBy || pc.Order != q.Order {
+			return invalidField("cursor", "cursor", "无效")
+		}
+		q.cursor = pc
+	}
+	return nil
+}
+
+// applyFilters 把过滤条件拼到查询上，不包含分页和排序，统计总数时也复用
+func (q *PostQuery) applyFilters(tx *gorm.DB) *gorm.DB {
+	// 已发布的所有人可见，其它状态只能看自己的
+	tx = tx.Where("posts.status = ?", q.Status)
+	if q.Status != StatusPublished {
+		tx = tx.Where("posts.user_id = ?", q.viewerID)
+	}
+	if q.AuthorID != 0 {
+		tx = tx.Where("posts.user_id = ?", q.AuthorID)
+	}
+	if q.followerID != 0 {
+		followees := tx.Session(&gorm.Session{NewDB: true}).Model(&Follow{}).Select("followee_id").Where("follower_id = ?", q.followerID)
+		tx = tx.Where("posts.user_id IN (?)", followees)
+	}
+	if q.Author != "" {
+		users := tx.Session(&gorm.Session{NewDB: true}).Model(&User{}).Select("id").Where("username = ?", q.Author)
+		tx = tx.Where("posts.user_id IN (?)", users)
+	}
+	if q.start != nil {
+		tx = tx.Where("posts.created_at >= ?", *q.start)
+	}
+	if q.end != nil {
+		tx = tx.Where("posts.created_at < ?", *q.end)
+	}
+	if kw := strings.TrimSpace(q.Keyword); kw != "" {
+		tx = tx.Where("LOWER(posts.title) LIKE ?", "%"+strings.ToLower(kw)+"%")
+	}
+	if q.Tag != "" {
+		tagged := tx.Session(&gorm.Session{NewDB: true}).Table("post_tags").
+			Select("post_tags.post_id").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").
+			Where("tags.name = ?", q.Tag)
+		tx = tx.Where("posts.id IN (?)", tagged)
+	}
+	if q.Category != "" {
+		categories := tx.Session(&gorm.Session{NewDB: true}).Model(&Category{}).Select("id").Where("name = ?", q.Category)
+		tx = tx.Where("posts.category_id IN (?)", categories)
+	}
+	return tx
+}
+
+// applyPage 拼接排序和分页条件；id作为第二排序键，保证顺序稳定
+func (q *PostQuery) applyPage(tx *gorm.DB) *gorm.DB {
+	col := "posts." + q.SortBy
+	if q.cursor != nil {
+		op := "<"
+		if q.Order == "asc" {
+			op = ">"
+		}
+		tx = tx.Where("("+col+" "+op+" ?) OR ("+col+" = ? AND posts.id "+op+" ?)",
+			q.cursor.Value, q.cursor.Value, q.cursor.ID)
+	} else {
+		tx = tx.Offset((q.Page - 1) * q.PageSize)
+	}
+	// 多取一条，用来判断是否还有下一页
+	return tx.Order(col + " " + q.Order).Order("posts.id " + q.Order).Limit(q.PageSize + 1)
+}
+
+// sortValue 取出文章在当前排序字段上的值，用于生成游标
+func (q *PostQuery) sortValue(p *Post) time.Time {
+	if q.SortBy == "updated_at" {
+		return p.UpdatedAt
+	}
+	return p.CreatedAt
+}
+
+// Paginate 截掉多取的那一条，并生成分页信息
+func (q *PostQuery) Paginate(posts []Post, total int64) ([]Post, Pagination) {
+	page := Pagination{PageSize: q.PageSize, Total: total}
+	if q.cursor == nil {
+		page.Page = q.Page
+	}
+	if len(posts) > q.PageSize {
+		posts = posts[:q.PageSize]
+		last := &posts[len(posts)-1]
+		page.NextCursor = postCursor{Value: q.sortValue(last), ID: last.ID, SortBy: q.SortBy, Order: q.Order}.encode()
+	}
+	return posts, page
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"blog-server/apperr"
+)
+
+func TestPostQueryNormalize(t *testing.T) {
+	q := PostQuery{PageSize: 1000, Order: "ASC"}
+	if err := q.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if q.Page != 1 || q.PageSize != MaxPageSize || q.SortBy != "created_at" || q.Order != "asc" || q.Status != StatusPublished {
+		t.Errorf("补全后的参数不对: page=%d page_size=%d sort_by=%s order=%s status=%s", q.Page, q.PageSize, q.SortBy, q.Order, q.Status)
+	}
+
+	// 不合法的参数返回对应字段的错误
+	cases := []struct {
+		name  string
+		q     PostQuery
+		field string
+	}{
+		{"排序字段", PostQuery{SortBy: "title"}, "sort_by"},
+		{"排序方向", PostQuery{Order: "up"}, "order"},
+		{"开始日期", PostQuery{StartDate: "2024/01/01"}, "start_date"},
+		{"结束日期", PostQuery{EndDate: "yesterday"}, "end_date"},
+		{"游客看草稿", PostQuery{Status: StatusDraft}, "status"},
+		{"未知状态", PostQuery{Status: "deleted", viewerID: 1}, "status"},
+		{"游标", PostQuery{Cursor: "not-a-cursor"}, "cursor"},
+	}
+	for _, tc := range cases {
+		err := tc.q.Normalize()
+		var e *apperr.Error
+		if !errors.As(err, &e) || len(e.Fields) != 1 || e.Fields[0].Field != tc.field {
+			t.Errorf("%s: 期望 %s 字段错误，得到 %v", tc.name, tc.field, err)
+		}
+	}
+}
+
+func TestPostQueryEndDate(t *testing.T) {
+	// 只传日期时包含当天全天，终点是第二天零点
+	q := PostQuery{StartDate: "2024-03-01", EndDate: "2024-03-31"}
+	if err := q.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local); !q.start.Equal(want) {
+		t.Errorf("start = %v，期望 %v", q.start, want)
+	}
+	if want := time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local); !q.end.Equal(want) {
+		t.Errorf("end = %v，期望 %v", q.end, want)
+	}
+
+	// 带时间的按原样使用
+	q = PostQuery{EndDate: "2024-03-31T12:00:00Z"}
+	if err := q.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC); !q.end.Equal(want) {
+		t.Errorf("end = %v，期望 %v", q.end, want)
+	}
+}
+
+func TestPostQueryCursor(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	for i := 0; i < 5; i++ {
+		if err := repos.Posts.Create(ctx, &Post{Title: "文章", Content: "内容", UserID: 1, Status: StatusPublished}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 按游标翻完所有页，每页2条，顺序是ID倒序且不重复不遗漏
+	var ids []uint
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("游标翻页没有结束")
+		}
+		q := PostQuery{PageSize: 2, Cursor: cursor}
+		if err := q.Normalize(); err != nil {
+			t.Fatal(err)
+		}
+		posts, total, err := repos.Posts.List(ctx, &q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		posts, page := q.Paginate(posts, total)
+		if page.Total != 5 || (cursor != "") != (page.Page == 0) {
+			t.Errorf("分页信息不对: %+v", page) // 第一页是页码分页，之后的游标分页不返回页码
+		}
+		for _, p := range posts {
+			ids = append(ids, p.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	want := []uint{5, 4, 3, 2, 1}
+	if len(ids) != len(want) {
+		t.Fatalf("翻页结果 %v，期望 %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("翻页结果 %v，期望 %v", ids, want)
+		}
+	}
+
+	// 游标换了排序方式就不能再用
+	q := PostQuery{PageSize: 2}
+	if err := q.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	posts, total, _ := repos.Posts.List(ctx, &q)
+	_, page := q.Paginate(posts, total)
+	for _, other := range []PostQuery{
+		{PageSize: 2, Cursor: page.NextCursor, SortBy: "updated_at"},
+		{PageSize: 2, Cursor: page.NextCursor, Order: "asc"},
+	} {
+		if err := other.Normalize(); err == nil {
+			t.Errorf("sort_by=%q order=%q 时沿用旧游标应该报错", other.SortBy, other.Order)
+		}
+	}
+}
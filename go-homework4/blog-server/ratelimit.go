@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 限流：令牌桶，公开接口按IP，需要登录的接口按用户 ======================
+// 每个接口可以在配置 rate_limit.routes 里单独设置策略，没有单独配置的接口共用 rate_limit.default 的桶
+// 令牌桶的状态放在RateLimitStore里：单实例部署用内存实现；多实例部署时实现同样的接口放到Redis等共享存储即可
+
+// RateLimitResult 取令牌的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // 桶里还剩几个令牌
+	RetryAfter time.Duration // 被拒绝时，多久后会有新的令牌
+	Reset      time.Duration // 多久后桶会重新装满
+}
+
+// RateLimitStore 令牌桶存储，实现必须并发安全
+type RateLimitStore interface {
+	// Take 从key对应的桶里取一个令牌，桶不存在时按装满的桶处理
+	Take(ctx context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error)
+}
+
+// rateKeyFunc 从请求里取出限流的对象
+type rateKeyFunc func(c *gin.Context) string
+
+// rateKeyIP 按客户端IP限流；IP取自 X-Forwarded-For 时只信任 server.trusted_proxies 里的代理
+func rateKeyIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// rateKeyUser 按登录用户限流，必须放在AuthMiddleware之后；没有用户时退回按IP
+func rateKeyUser(c *gin.Context) string {
+	if id := c.GetUint("userID"); id != 0 {
+		return "user:" + strconv.FormatUint(uint64(id), 10)
+	}
+	return rateKeyIP(c)
+}
+
+// RateLimit 限流中间件，key决定按什么限流
+// 响应头按IETF RateLimit头部草案返回额度，超出时返回429和Retry-After
+func (s *Server) RateLimit(key rateKeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := s.cfg.RateLimit
+		if !cfg.Enabled || s.limits == nil {
+			c.Next()
+			return
+		}
+
+		// 单独配置了策略的接口用自己的桶，其它接口共用默认的桶
+		route := c.Request.Method + " " + c.FullPath()
+		bucket := route
+		policy, ok := cfg.Routes[route]
+		if !ok {
+			bucket, policy = "default", cfg.Default
+		}
+		if policy.Limit <= 0 {
+			c.Next()
+			return
+		}
+
+		res, err := s.limits.Take(c.Request.Context(), bucket+"|"+key(c), policy, time.Now())
+		if err != nil {
+			// 限流存储出问题时放行，不能因为限流把整个服务拖垮
+			log.Errorf("限流检查失败: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", strconv.Itoa(policy.capacity())+";w="+strconv.Itoa(ceilSeconds(policy.Period.Duration)))
+		c.Header("RateLimit-Limit", strconv.Itoa(policy.capacity()))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			retry := ceilSeconds(res.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retry))
+			fail(c, errRateLimited.WithMessage("请求太频繁，请"+strconv.Itoa(retry)+"秒后再试"))
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// capacity 桶的容量，burst没配置时等于limit
+func (p RateLimitPolicy) capacity() int {
+	if p.Burst > 0 {
+		return p.Burst
+	}
+	return p.Limit
+}
+
+// take 令牌桶的计算：按经过的时间补充令牌，再尝试取一个；tokens和last是桶原来的状态
+// 共享存储的实现也可以直接用这个函数，只需要把tokens和last原子地读出、写回
+func (p RateLimitPolicy) take(tokens float64, last, now time.Time) (float64, RateLimitResult) {
+	capacity := float64(p.capacity())
+	rate := float64(p.Limit) / p.Period.Seconds() // 每秒补充的令牌数
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+
+	var res RateLimitResult
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	res.Remaining = int(tokens)
+	res.Reset = time.Duration((capacity - tokens) / rate * float64(time.Second))
+	return tokens, res
+}
+
+// ---------------------- 内存实现 ----------------------
+
+// MemoryRateLimitStore 进程内的令牌桶存储，只适合单实例部署
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // 上次取令牌的时间
+	fullAt time.Time // 到这个时间桶就装满了，之后可以直接删掉
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, policy RateLimitPolicy, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 每分钟清理一次已经装满的桶，它们和不存在的桶没有区别，避免大量IP把内存占满
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, b := range s.buckets {
+			if !now.Before(b.fullAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(policy.capacity()), last: now}
+		s.buckets[key] = b
+	}
+	tokens, res := policy.take(b.tokens, b.last, now)
+	b.tokens, b.last, b.fullAt = tokens, now, now.Add(res.Reset)
+	return res, nil
+}
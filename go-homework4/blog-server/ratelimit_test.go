@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	perMinute := RateLimitPolicy{Limit: 6, Period: Duration{time.Minute}}       // 每10秒补一个，桶容量6
+	burst := RateLimitPolicy{Limit: 1, Period: Duration{time.Second}, Burst: 3} // 每秒补一个，桶容量3
+	cases := []struct {
+		name       string
+		policy     RateLimitPolicy
+		tokens     float64 // 桶原来的令牌数
+		elapsed    time.Duration
+		allowed    bool
+		left       float64 // 取完之后桶里的令牌数
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		{"满桶", perMinute, 6, 0, true, 5, 5, 0, 10 * time.Second},
+		{"空桶", perMinute, 0, 0, false, 0, 0, 10 * time.Second, time.Minute},
+		{"空桶等了一半", perMinute, 0, 5 * time.Second, false, 0.5, 0, 5 * time.Second, 55 * time.Second},
+		{"空桶等够了", perMinute, 0, 10 * time.Second, true, 0, 0, 0, time.Minute},
+		{"补充不超过容量", perMinute, 5, time.Hour, true, 5, 5, 0, 10 * time.Second},
+		{"时钟回拨不补充", perMinute, 0.5, -time.Minute, false, 0.5, 0, 5 * time.Second, 55 * time.Second},
+		{"burst容量", burst, 3, 0, true, 2, 2, 0, time.Second},
+		{"burst空桶", burst, 0.25, 0, false, 0.25, 0, 750 * time.Millisecond, 2750 * time.Millisecond},
+	}
+	for _, tc := range cases {
+		left, res := tc.policy.take(tc.tokens, t0, t0.Add(tc.elapsed))
+		if res.Allowed != tc.allowed || res.Remaining != tc.remaining || res.RetryAfter != tc.retryAfter || res.Reset != tc.reset {
+			t.Errorf("%s: 结果 %+v，期望 allowed=%v remaining=%d retry_after=%v reset=%v",
+				tc.name, res, tc.allowed, tc.remaining, tc.retryAfter, tc.reset)
+		}
+		if left != tc.left {
+			t.Errorf("%s: 桶里剩 %v 个令牌，期望 %v", tc.name, left, tc.left)
+		}
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRateLimitStore()
+	policy := RateLimitPolicy{Limit: 2, Period: Duration{time.Minute}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	take := func(key string, at time.Time) RateLimitResult {
+		t.Helper()
+		res, err := store.Take(ctx, key, policy, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	// 每个key的桶互不影响
+	if !take("a", now).Allowed || !take("a", now).Allowed || take("a", now).Allowed {
+		t.Error("容量2的桶应该放行两次后拒绝")
+	}
+	if !take("b", now).Allowed {
+		t.Error("另一个key的桶不受影响")
+	}
+	if !take("a", now.Add(30*time.Second)).Allowed {
+		t.Error("30秒后应该补充一个令牌")
+	}
+
+	// 一分钟后清理已经装满的桶：b早就满了，a在 30s+60s 之后才满
+	take("c", now.Add(61*time.Second))
+	if _, ok := store.buckets["b"]; ok {
+		t.Error("已经装满的桶没有被清理")
+	}
+	if _, ok := store.buckets["a"]; !ok {
+		t.Error("还没装满的桶被清理了")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	s.cfg.RateLimit.Enabled = true
+	s.cfg.RateLimit.Routes = map[string]RateLimitPolicy{
+		"GET /feed.xml": {Limit: 2, Period: Duration{time.Minute}},
+	}
+
+	get := func(url, ip string, want int) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", url, nil)
+		req.RemoteAddr = ip + ":1234"
+		return sc.send(req, "", want)
+	}
+	w := get("/feed.xml", "10.0.0.1", 200)
+	if w.Header().Get("RateLimit-Policy") != "2;w=60" || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("额度响应头不对: %v", w.Header())
+	}
+	get("/feed.xml", "10.0.0.1", 200)
+	w = get("/feed.xml", "10.0.0.1", 429)
+	// 每30秒补一个令牌
+	if w.Header().Get("Retry-After") != "30" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("被限流时的响应头不对: %v", w.Header())
+	}
+
+	// 按IP分桶；没有单独配置的接口用默认的桶，不受影响
+	get("/feed.xml", "10.0.0.2", 200)
+	get("/atom.xml", "10.0.0.1", 200)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 权限控制：角色 + 权限 + 操作审计 ======================
+// 每个用户有一个角色，角色决定拥有哪些权限；角色写在JWT里，修改角色后下次签发令牌时生效
+// 作者操作自己的内容不需要额外权限；操作别人的内容属于特权操作，需要对应权限，并写入审计日志
+
+// 角色
+const (
+	RoleUser      = "user"      // 普通用户：只能管理自己的内容
+	RoleModerator = "moderator" // 版主：可以编辑、删除任何文章和评论
+	RoleAdmin     = "admin"     // 管理员：版主的全部权限 + 管理用户角色、查看审计日志
+)
+
+// Permission 权限
+type Permission string
+
+const (
+	PermEditAnyPost      Permission = "post:edit_any"
+	PermDeleteAnyPost    Permission = "post:delete_any"
+	PermEditAnyComment   Permission = "comment:edit_any"
+	PermDeleteAnyComment Permission = "comment:delete_any"
+	PermManageRoles      Permission = "user:manage_roles"
+	PermViewAuditLog     Permission = "audit:view"
+)
+
+// rolePermissions 角色拥有的权限
+var rolePermissions = map[string][]Permission{
+	RoleUser:      {},
+	RoleModerator: {PermEditAnyPost, PermDeleteAnyPost, PermEditAnyComment, PermDeleteAnyComment},
+	RoleAdmin: {PermEditAnyPost, PermDeleteAnyPost, PermEditAnyComment, PermDeleteAnyComment,
+		PermManageRoles, PermViewAuditLog},
+}
+
+func validRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission 判断角色是否拥有某个权限，未知角色没有任何权限
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// can 当前登录用户是否拥有某个权限（角色由AuthMiddleware存入上下文）
+func can(c *gin.Context, perm Permission) bool {
+	return HasPermission(c.GetString("role"), perm)
+}
+
+// RequirePermission Gin中间件：要求当前用户拥有指定权限，必须放在AuthMiddleware之后
+func RequirePermission(perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !can(c, perm) {
+			fail(c, errPermissionDenied)
+			return
+		}
+		c.Next()
+	}
+}
+
+// AuditLog 审计日志表：记录所有特权操作是谁、以什么角色、对什么做了什么
+type AuditLog struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	ActorID    uint      `gorm:"index;not null" json:"actor_id"`              // 操作人
+	ActorRole  string    `gorm:"not null;type:varchar(20)" json:"actor_role"` // 操作时的角色
+	Action     string    `gorm:"not null;type:varchar(50)" json:"action"`     // 如 post.delete、user.role
+	TargetType string    `gorm:"not null;type:varchar(20)" json:"target_type"`
+	TargetID   uint      `gorm:"not null" json:"target_id"`
+	Detail     string    `gorm:"type:text" json:"detail"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// 审计动作
+const (
+	AuditPostUpdate       = "post.update"
+	AuditPostDelete       = "post.delete"
+	AuditCommentUpdate    = "comment.update"
+	AuditCommentDelete    = "comment.delete"
+	AuditUserRole         = "user.role"
+	AuditAttachmentDelete = "attachment.delete"
+)
+
+// audit 记录一次特权操作；写审计失败只打日志，不影响已经完成的操作
+func (s *Server) audit(c *gin.Context, action, targetType string, targetID uint, detail string) {
+	entry := AuditLog{
+		ActorID:    c.GetUint("userID"),
+		ActorRole:  c.GetString("role"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Detail:     detail,
+	}
+	if err := s.Audits.Create(c.Request.Context(), &entry); err != nil {
+		log.Errorf("写入审计日志失败: %v", err)
+		return
+	}
+	log.Infof("特权操作: 用户ID:%d(%s) %s %s:%d %s", entry.ActorID, entry.ActorRole, action, targetType, targetID, detail)
+}
+
+// authorize 判断当前用户能否操作ownerID名下的内容：本人直接放行，否则需要perm权限
+// 返回privileged表示这是一次越过作者身份的特权操作，需要写审计日志
+func authorize(c *gin.Context, ownerID uint, perm Permission) (ok, privileged bool) {
+	if ownerID == c.GetUint("userID") {
+		return true, false
+	}
+	if can(c, perm) {
+		return true, true
+	}
+	return false, false
+}
+
+// bootstrapAdmins 启动时把配置里的用户名设置为管理员，解决第一个管理员从哪来的问题
+func bootstrapAdmins(ctx context.Context, users UserRepository, usernames []string) error {
+	for _, name := range usernames {
+		user, err := users.FindByUsername(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			log.Warnf("配置的管理员用户不存在，已跳过: %s", name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if user.Role == RoleAdmin {
+			continue
+		}
+		if err := users.UpdateRole(ctx, user.ID, RoleAdmin); err != nil {
+			return err
+		}
+		log.Infof("已将用户 %s 设置为管理员", name)
+	}
+	return nil
+}
+
+// ====================== 管理接口 ======================
+
+// roleRequest 修改用户角色的请求体
+type roleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// UpdateUserRole 修改用户角色 PUT /api/admin/users/:id/role 【需要 user:manage_roles 权限】
+func (s *Server) UpdateUserRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, errInvalidUserID)
+		return
+	}
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	if !validRole(req.Role) {
+		fail(c, errInvalidRole)
+		return
+	}
+	// 防止管理员把自己降级后系统里没有管理员
+	if uint(id) == c.GetUint("userID") {
+		fail(c, errChangeOwnRole)
+		return
+	}
+
+	user, err := s.Users.FindByID(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			fail(c, errUserNotFound)
+		} else {
+			log.Errorf("查询用户失败: %v", err)
+			fail(c, apperr.Internal("修改角色失败"))
+		}
+		return
+	}
+	if err := s.Users.UpdateRole(c.Request.Context(), user.ID, req.Role); err != nil {
+		log.Errorf("修改用户角色失败: %v", err)
+		fail(c, apperr.Internal("修改角色失败"))
+		return
+	}
+
+	s.audit(c, AuditUserRole, "user", user.ID, user.Role+" -> "+req.Role)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "角色修改成功，用户重新登录或刷新令牌后生效"})
+}
+
+// ListAuditLogs 查看审计日志 GET /api/admin/audit-logs 【需要 audit:view 权限】
+// 支持 actor_id 过滤和 page/page_size 分页，按时间倒序
+func (s *Server) ListAuditLogs(c *gin.Context) {
+	var q AuditQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	q.normalize()
+
+	logs, total, err := s.Audits.List(c.Request.Context(), &q)
+	if err != nil {
+		log.Errorf("获取审计日志失败: %v", err)
+		fail(c, apperr.Internal("获取审计日志失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":       200,
+		"msg":        "获取成功",
+		"data":       logs,
+		"pagination": Pagination{Page: q.Page, PageSize: q.PageSize, Total: total},
+	})
+}
+
+// AuditQuery 审计日志查询参数
+type AuditQuery struct {
+	ActorID  uint `form:"actor_id"`
+	Page     int  `form:"page"`
+	PageSize int  `form:"page_size"`
+}
+
+func (q *AuditQuery) normalize() {
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestModeratorOverrideAndAudit(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	sc.login("root")
+	if err := bootstrapAdmins(context.Background(), s.Users, []string{"root"}); err != nil {
+		t.Fatal(err)
+	}
+	root, _ := sc.loginPair("root")
+	alice := sc.login("alice")
+	carol := sc.login("carol")
+	_, carolRefresh := sc.loginPair("carol")
+	user, err := s.Users.FindByUsername(context.Background(), "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	carolID := user.ID
+
+	first := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "一", "content": "内容"}, 200), "id")
+	second := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "二", "content": "内容"}, 200), "id")
+	edit := func(token string, id uint, want int) map[string]interface{} {
+		t.Helper()
+		return sc.call("PUT", fmt.Sprint("/api/posts/", id), token, gin.H{"content": "改过的内容"}, want)
+	}
+
+	// 普通用户不能改别人的文章，也不能用管理接口
+	if code := errorCode(edit(carol, first, 403)); code != errPostForbidden.Code {
+		t.Errorf("错误码 %s，期望 %s", code, errPostForbidden.Code)
+	}
+	sc.call("DELETE", fmt.Sprint("/api/posts/", second), carol, nil, 403)
+	sc.call("GET", "/api/admin/audit-logs", carol, nil, 403)
+
+	// 改成版主：角色写在JWT里，旧令牌还是普通用户，刷新或重新登录后生效
+	sc.call("PUT", fmt.Sprint("/api/admin/users/", carolID, "/role"), root, gin.H{"role": RoleModerator}, 200)
+	edit(carol, first, 403)
+	moderator, _ := sc.refresh(carolRefresh)
+	edit(moderator, first, 200)
+	sc.call("DELETE", fmt.Sprint("/api/posts/", second), moderator, nil, 200)
+	sc.call("GET", "/api/admin/audit-logs", moderator, nil, 403) // 版主没有 audit:view 权限
+	edit(alice, first, 200)                                      // 作者改自己的文章不算特权操作
+
+	// 审计日志记下操作人和操作时的角色
+	logs := sc.call("GET", fmt.Sprint("/api/admin/audit-logs?actor_id=", carolID), root, nil, 200)["data"].([]interface{})
+	want := []struct {
+		action string
+		target uint
+	}{{AuditPostDelete, second}, {AuditPostUpdate, first}} // 按时间倒序
+	if len(logs) != len(want) {
+		t.Fatalf("版主有 %d 条审计日志，期望 %d 条: %v", len(logs), len(want), logs)
+	}
+	for i, w := range want {
+		entry := logs[i].(map[string]interface{})
+		if entry["actor_id"] != float64(carolID) || entry["actor_role"] != RoleModerator ||
+			entry["action"] != w.action || entry["target_type"] != "post" || entry["target_id"] != float64(w.target) {
+			t.Errorf("第 %d 条审计日志是 %v，期望 %s post:%d", i, entry, w.action, w.target)
+		}
+	}
+	all := sc.call("GET", "/api/admin/audit-logs", root, nil, 200)["data"].([]interface{})
+	if len(all) != 3 || all[2].(map[string]interface{})["action"] != AuditUserRole || all[2].(map[string]interface{})["actor_role"] != RoleAdmin {
+		t.Errorf("全部审计日志是 %v，期望版主的两条和修改角色的一条", all)
+	}
+
+	// 降回普通用户后重新登录，权限随之收回
+	sc.call("PUT", fmt.Sprint("/api/admin/users/", carolID, "/role"), root, gin.H{"role": RoleUser}, 200)
+	carol, _ = sc.loginPair("carol")
+	edit(carol, first, 403)
+}
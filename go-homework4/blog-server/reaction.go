@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 表态：给文章和评论点赞、加表情 ======================
+// 同一个用户对同一个目标的每种表态最多一条（唯一索引），再点一次就是取消
+// 文章和评论的响应里都带上各种表态的数量；带了有效token时 reacted 表示当前用户是否表过这种态
+
+const (
+	ReactionTargetPost    = "post"
+	ReactionTargetComment = "comment"
+)
+
+// ReactionKinds 支持的表态，响应里的统计也按这个顺序排列
+var ReactionKinds = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+// Reaction 表态表
+type Reaction struct {
+	ID         uint   `gorm:"primarykey"`
+	UserID     uint   `gorm:"not null;uniqueIndex:idx_reaction_unique"`
+	TargetType string `gorm:"not null;type:varchar(20);uniqueIndex:idx_reaction_unique;index:idx_reactions_target"` // post/comment
+	TargetID   uint   `gorm:"not null;uniqueIndex:idx_reaction_unique;index:idx_reactions_target"`
+	Kind       string `gorm:"not null;type:varchar(20);uniqueIndex:idx_reaction_unique"`
+	CreatedAt  time.Time
+}
+
+// ReactionCount 某种表态的数量，只返回数量大于0的
+type ReactionCount struct {
+	Kind    string `json:"kind"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"` // 当前用户是否表过这种态，游客总是false
+}
+
+// reactionTally 仓储汇总表态时的一行：某个目标某种表态的数量
+type reactionTally struct {
+	TargetID uint
+	Kind     string
+	Count    int64
+}
+
+// groupReactions 把汇总结果按目标分组，每组按 ReactionKinds 的顺序排列；mine是当前用户表过的态
+func groupReactions(tallies []reactionTally, mine map[uint]map[string]bool) map[uint][]ReactionCount {
+	counts := make(map[uint]map[string]int64)
+	for _, t := range tallies {
+		if counts[t.TargetID] == nil {
+			counts[t.TargetID] = make(map[string]int64)
+		}
+		counts[t.TargetID][t.Kind] += t.Count
+	}
+	out := make(map[uint][]ReactionCount, len(counts))
+	for id, byKind := range counts {
+		list := []ReactionCount{}
+		for _, kind := range ReactionKinds {
+			if n := byKind[kind]; n > 0 {
+				list = append(list, ReactionCount{Kind: kind, Count: n, Reacted: mine[id][kind]})
+			}
+		}
+		out[id] = list
+	}
+	return out
+}
+
+// ReactionRequest 表态的请求体
+type ReactionRequest struct {
+	Kind string `json:"kind" binding:"required,oneof=like love laugh wow sad angry"`
+}
+
+// ReactionResult 表态接口的响应：这次是加上还是取消，以及目标最新的统计
+type ReactionResult struct {
+	Kind      string          `json:"kind"`
+	Reacted   bool            `json:"reacted"` // true表示加上了，false表示取消了
+	Reactions []ReactionCount `json:"reactions"`
+}
+
+// reactionsOf 查出一批目标的表态统计，查询失败只记日志，返回空结果，不影响文章和评论本身的返回
+func (s *Server) reactionsOf(c *gin.Context, targetType string, ids []uint) map[uint][]ReactionCount {
+	if len(ids) == 0 {
+		return nil
+	}
+	summaries, err := s.Reactions.Summaries(c.Request.Context(), targetType, ids, c.GetUint("userID"))
+	if err != nil {
+		log.Errorf("查询表态统计失败: %v", err)
+		return nil
+	}
+	return summaries
+}
+
+// viewPosts 文章转成响应结构并带上表态统计
+func (s *Server) viewPosts(c *gin.Context, posts []Post) []PostView {
+	views := postViews(posts)
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	summaries := s.reactionsOf(c, ReactionTargetPost, ids)
+	for i := range views {
+		if r, ok := summaries[views[i].ID]; ok {
+			views[i].Reactions = r
+		}
+	}
+	return views
+}
+
+// viewPost 同viewPosts，只有一篇文章
+func (s *Server) viewPost(c *gin.Context, post *Post) PostView {
+	return s.viewPosts(c, []Post{*post})[0]
+}
+
+// withCommentReactions 给评论树（或平铺列表）里的每条评论带上表态统计，墓碑不统计
+func (s *Server) withCommentReactions(c *gin.Context, nodes []*CommentNode) []*CommentNode {
+	var ids []uint
+	var collect func([]*CommentNode)
+	collect = func(nodes []*CommentNode) {
+		for _, n := range nodes {
+			if !n.Deleted {
+				ids = append(ids, n.ID)
+			}
+			collect(n.Replies)
+		}
+	}
+	collect(nodes)
+	summaries := s.reactionsOf(c, ReactionTargetComment, ids)
+
+	var fill func([]*CommentNode)
+	fill = func(nodes []*CommentNode) {
+		for _, n := range nodes {
+			if r, ok := summaries[n.ID]; ok && !n.Deleted {
+				n.Reactions = r
+			}
+			fill(n.Replies)
+		}
+	}
+	fill(nodes)
+	return nodes
+}
+
+// toggleReaction 加上或取消表态，返回给客户端最新的统计
+func (s *Server) toggleReaction(c *gin.Context, targetType string, targetID uint) {
+	var req ReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	userID := c.GetUint("userID")
+	reaction := Reaction{UserID: userID, TargetType: targetType, TargetID: targetID, Kind: req.Kind}
+	added, err := s.Reactions.Toggle(c.Request.Context(), &reaction)
+	if err != nil {
+		log.Errorf("表态失败: %v", err)
+		fail(c, apperr.Internal("表态失败"))
+		return
+	}
+
+	result := ReactionResult{Kind: req.Kind, Reacted: added, Reactions: []ReactionCount{}}
+	if r, ok := s.reactionsOf(c, targetType, []uint{targetID})[targetID]; ok {
+		result.Reactions = r
+	}
+	msg := "已取消表态"
+	if added {
+		msg = "表态成功！"
+	}
+	log.Infof("用户ID:%d 对%s ID:%d 表态:%s，%s", userID, targetType, targetID, req.Kind, msg)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": msg, "data": result})
+}
+
+// ReactToPost 给文章表态 POST /api/posts/:id/reactions 【需要登录】
+// body：{"kind":"like"}，已经表过同样的态时取消
+func (s *Server) ReactToPost(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, errInvalidPostID)
+		return
+	}
+	post, err := s.Posts.FindByID(c.Request.Context(), uint(id))
+	if err != nil || !canViewPost(c, post) {
+		fail(c, errPostNotFound)
+		return
+	}
+	s.toggleReaction(c, ReactionTargetPost, post.ID)
+}
+
+// ReactToComment 给评论表态 POST /api/comments/:id/reactions 【需要登录】
+// body同ReactToPost；已删除的评论和看不到的文章下的评论都当作不存在
+func (s *Server) ReactToComment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, errInvalidCommentID)
+		return
+	}
+	comment, err := s.Comments.FindByID(c.Request.Context(), uint(id))
+	if err != nil {
+		fail(c, errCommentNotFound)
+		return
+	}
+	if post, err := s.Posts.FindByID(c.Request.Context(), comment.PostID); err != nil || !canViewPost(c, post) {
+		fail(c, errCommentNotFound)
+		return
+	}
+	s.toggleReaction(c, ReactionTargetComment, comment.ID)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReactions(t *testing.T) {
+	_, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	bob := sc.login("bob")
+	post := sc.call("POST", "/api/posts", alice, gin.H{"title": "Hello", "content": "hi"}, 200)
+	postID := fmt.Sprint(sc.id(post, "id"))
+	reply := sc.id(sc.call("POST", "/api/comments", bob, gin.H{"post_id": sc.id(post, "id"), "content": "nice"}, 200), "id")
+
+	// 再点一次同样的表态是取消
+	sc.call("POST", "/api/posts/"+postID+"/reactions", bob, gin.H{"kind": "like"}, 200)
+	sc.call("POST", "/api/posts/"+postID+"/reactions", alice, gin.H{"kind": "like"}, 200)
+	sc.call("POST", "/api/posts/"+postID+"/reactions", alice, gin.H{"kind": "love"}, 200)
+	resp := sc.call("POST", "/api/posts/"+postID+"/reactions", alice, gin.H{"kind": "love"}, 200)
+	if data := resp["data"].(map[string]interface{}); data["reacted"] != false || len(data["reactions"].([]interface{})) != 1 {
+		t.Errorf("取消表态后的统计不对: %v", data)
+	}
+
+	// 统计里标出当前用户表过的态，游客都是false
+	for token, want := range map[string]bool{alice: true, "": false} {
+		data := sc.call("GET", "/api/posts/"+postID, token, nil, 200)["data"].(map[string]interface{})
+		like := data["reactions"].([]interface{})[0].(map[string]interface{})
+		if like["kind"] != "like" || like["count"] != float64(2) || like["reacted"] != want {
+			t.Errorf("文章的表态统计不对: %v", like)
+		}
+	}
+
+	// 评论的表态；已删除的评论不能表态
+	sc.call("POST", fmt.Sprint("/api/comments/", reply, "/reactions"), alice, gin.H{"kind": "laugh"}, 200)
+	for _, cm := range sc.call("GET", "/api/posts/"+postID+"/comments?flat=true", "", nil, 200)["data"].([]interface{}) {
+		if cm := cm.(map[string]interface{}); cm["id"] == float64(reply) && len(cm["reactions"].([]interface{})) != 1 {
+			t.Errorf("评论的表态统计不对: %v", cm)
+		}
+	}
+	sc.call("DELETE", fmt.Sprint("/api/comments/", reply), bob, nil, 200)
+	sc.call("POST", fmt.Sprint("/api/comments/", reply, "/reactions"), alice, gin.H{"kind": "laugh"}, 404)
+}
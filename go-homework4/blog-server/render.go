@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// ====================== 文章内容渲染：Markdown / 纯文本 / HTML ======================
+// 数据库里只保存原文，读取详情时在服务端渲染成HTML，所有结果都经过同一个白名单过滤器，
+// 客户端可以直接插入页面而不用担心脚本注入；Markdown还会给标题加上锚点id并生成目录
+
+const (
+	FormatMarkdown = "markdown" // Markdown，新文章的默认格式
+	FormatPlain    = "plain"    // 纯文本，老文章的格式
+	FormatHTML     = "html"     // HTML，只保留白名单里的标签和属性
+)
+
+func validFormat(format string) bool {
+	switch format {
+	case FormatMarkdown, FormatPlain, FormatHTML:
+		return true
+	}
+	return false
+}
+
+// TOCEntry 目录的一项，按标题在文中出现的顺序排列，用level还原层级
+type TOCEntry struct {
+	Level int    `json:"level"` // 1~6，对应h1~h6
+	Text  string `json:"text"`
+	ID    string `json:"id"` // 标题的锚点，链接写成 #id
+}
+
+// PostDetail 文章详情：原文字段不变，另外带上渲染后的HTML和目录
+type PostDetail struct {
+	PostView
+	ContentHTML string     `json:"content_html"`
+	TOC         []TOCEntry `json:"toc"`
+}
+
+var (
+	markdown = goldmark.New(
+		// 表格、删除线、任务列表、自动链接；表格对齐用align属性，style属性会被过滤掉
+		goldmark.WithExtensions(
+			extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+			extension.Strikethrough, extension.TaskList, extension.Linkify,
+		),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		// 不开启 html.WithUnsafe，Markdown里的原始HTML直接丢弃
+	)
+
+	headingIDPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+	// sanitizer 严格的白名单：只允许排版用的标签，链接和图片只允许http/https，不允许任何样式和事件属性
+	sanitizer = newSanitizer()
+)
+
+func newSanitizer() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("h1", "h2", "h3", "h4", "h5", "h6", "p", "br", "hr", "blockquote", "pre", "code",
+		"em", "strong", "del", "sub", "sup",
+		"ul", "ol", "li", "table", "thead", "tbody", "tr", "th", "td")
+	p.AllowAttrs("id").Matching(headingIDPattern).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	// GFM任务列表渲染出来的复选框
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowRelativeURLs(true)
+	p.RequireParseableURLs(true)
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.RequireNoFollowOnLinks(true)
+	p.AllowAttrs("src", "alt", "title").OnElements("img")
+	return p
+}
+
+// renderContent 把原文按格式渲染成安全的HTML，Markdown同时返回目录；未知格式按纯文本处理
+func renderContent(format, content string) (string, []TOCEntry, error) {
+	toc := []TOCEntry{}
+	switch format {
+	case FormatMarkdown:
+		src := []byte(content)
+		ctx := parser.NewContext(parser.WithIDs(newHeadingIDs()))
+		doc := markdown.Parser().Parse(text.NewReader(src), parser.WithContext(ctx))
+		toc = collectTOC(doc, src)
+		var buf bytes.Buffer
+		if err := markdown.Renderer().Render(&buf, src, doc); err != nil {
+			return "", nil, err
+		}
+		return sanitizer.Sanitize(buf.String()), toc, nil
+	case FormatHTML:
+		return sanitizer.Sanitize(content), toc, nil
+	default:
+		return renderPlain(content), toc, nil
+	}
+}
+
+// renderPlain 纯文本：转义后空行分段，段内换行保留
+func renderPlain(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	var b strings.Builder
+	for _, para := range strings.Split(content, "\n\n") {
+		para = strings.Trim(para, "\n")
+		if strings.TrimSpace(para) == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(para), "\n", "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
+
+// collectTOC 按出现顺序收集文档里的标题，锚点用解析时生成的id
+func collectTOC(doc ast.Node, src []byte) []TOCEntry {
+	toc := []TOCEntry{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		id, _ := heading.AttributeString("id")
+		idBytes, _ := id.([]byte)
+		toc = append(toc, TOCEntry{Level: heading.Level, Text: plainText(heading, src), ID: string(idBytes)})
+		return ast.WalkSkipChildren, nil
+	})
+	return toc
+}
+
+// plainText 取节点下的纯文字，去掉强调、链接等标记
+func plainText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := n.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(src))
+			if t.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(t.Value)
+		case *ast.CodeSpan:
+			for c := t.FirstChild(); c != nil; c = c.NextSibling() {
+				if s, ok := c.(*ast.Text); ok {
+					b.Write(s.Segment.Value(src))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// headingIDs 生成标题锚点：保留中文等各种文字和数字，空白换成 "-"，重复的加 -1、-2 后缀
+// goldmark默认的实现会丢掉所有非ASCII字符，中文标题都会变成 "heading"
+type headingIDs struct {
+	used map[string]bool
+}
+
+func newHeadingIDs() *headingIDs {
+	return &headingIDs{used: map[string]bool{}}
+}
+
+func (h *headingIDs) Generate(value []byte, _ ast.NodeKind) []byte {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(string(value)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-':
+			dash = true
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "section"
+	}
+	if h.used[id] {
+		for i := 1; ; i++ {
+			if next := fmt.Sprintf("%s-%d", id, i); !h.used[next] {
+				id = next
+				break
+			}
+		}
+	}
+	h.used[id] = true
+	return []byte(id)
+}
+
+func (h *headingIDs) Put(value []byte) {
+	h.used[string(value)] = true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderContentSanitize(t *testing.T) {
+	cases := []struct {
+		name, format, content string
+		want                  []string // 渲染结果里必须有的片段
+		reject                []string // 渲染结果里不能有的片段
+	}{
+		{"markdown javascript链接", FormatMarkdown, "[点我](javascript:alert(1))",
+			[]string{"点我"}, []string{"javascript:", "href"}},
+		{"markdown data图片", FormatMarkdown, "![x](data:image/svg+xml;base64,PHN2Zz4=)",
+			nil, []string{"data:", "src="}},
+		{"markdown 原始script", FormatMarkdown, "正文\n\n<script>alert(1)</script>\n\n<img src=x onerror=alert(1)>",
+			[]string{"<p>正文</p>"}, []string{"<script", "alert(1)", "onerror"}},
+		{"markdown 普通链接", FormatMarkdown, "[博客](https://example.com/a)",
+			[]string{`<a href="https://example.com/a" rel="nofollow">博客</a>`}, nil},
+		{"markdown 自动链接", FormatMarkdown, "见 https://example.com",
+			[]string{`href="https://example.com"`, `rel="nofollow"`}, nil},
+		{"html javascript链接", FormatHTML, `<a href="javascript:alert(1)">点我</a>`,
+			[]string{"点我"}, []string{"javascript:", "href"}},
+		{"html data图片", FormatHTML, `<img src="data:image/png;base64,AAAA" alt="x">`,
+			nil, []string{"data:", "src="}},
+		{"html script和事件属性", FormatHTML, `<p onclick="alert(1)" style="color:red">段落</p><script>alert(2)</script><img src="https://example.com/a.png" onerror="alert(3)">`,
+			[]string{"<p>段落</p>", `<img src="https://example.com/a.png">`}, []string{"<script", "alert", "onclick", "onerror", "style"}},
+		{"html 普通链接", FormatHTML, `<a href="https://example.com" target="_blank">博客</a>`,
+			[]string{`<a href="https://example.com" rel="nofollow">博客</a>`}, []string{"target"}},
+		{"plain 转义", FormatPlain, "<script>alert(1)</script>\n第二行",
+			[]string{"<p>&lt;script&gt;alert(1)&lt;/script&gt;<br>\n第二行</p>"}, []string{"<script"}},
+	}
+	for _, tc := range cases {
+		out, _, err := renderContent(tc.format, tc.content)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		for _, s := range tc.want {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s: 结果里没有 %q：%s", tc.name, s, out)
+			}
+		}
+		for _, s := range tc.reject {
+			if strings.Contains(out, s) {
+				t.Errorf("%s: 结果里不能有 %q：%s", tc.name, s, out)
+			}
+		}
+	}
+}
+
+func TestRenderContentHeadings(t *testing.T) {
+	out, toc, err := renderContent(FormatMarkdown, "# 你好 世界\n\n正文\n\n## 你好 世界\n\n## *Go* 语言 `1.22`\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 中文标题保留原文，重复的加后缀，HTML里的id和目录一致
+	wantTOC := []TOCEntry{
+		{Level: 1, Text: "你好 世界", ID: "你好-世界"},
+		{Level: 2, Text: "你好 世界", ID: "你好-世界-1"},
+		{Level: 2, Text: "Go 语言 1.22", ID: "go-语言-122"},
+	}
+	if !reflect.DeepEqual(toc, wantTOC) {
+		t.Errorf("目录是 %+v，期望 %+v", toc, wantTOC)
+	}
+	for _, s := range []string{`<h1 id="你好-世界">你好 世界</h1>`, `<h2 id="你好-世界-1">你好 世界</h2>`, `<h2 id="go-语言-122">`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("结果里没有 %q：%s", s, out)
+		}
+	}
+
+	// 其它格式没有目录
+	for _, format := range []string{FormatHTML, FormatPlain} {
+		if _, toc, _ := renderContent(format, "<h1>标题</h1>"); len(toc) != 0 {
+			t.Errorf("%s 格式不应该有目录: %+v", format, toc)
+		}
+	}
+}
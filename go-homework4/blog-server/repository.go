@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ====================== 数据访问层：仓储接口 ======================
+// 接口只描述业务需要的读写操作，handler通过Server拿到具体实现：
+// 线上用GORM实现（repository_gorm.go），单元测试用内存实现（repository_memory.go），不需要真实数据库
+
+var (
+	ErrNotFound  = errors.New("记录不存在")
+	ErrDuplicate = errors.New("记录已存在") // 违反唯一约束，如用户名/邮箱重复
+)
+
+// DuplicateError 违反唯一约束时的详细错误，errors.Is(err, ErrDuplicate) 成立
+// Field是冲突的列名，联合唯一约束时是用逗号连接的多个列名，解析不出来时为空
+type DuplicateError struct {
+	Table string
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	return ErrDuplicate.Error() + "：" + e.Table + "." + e.Field
+}
+
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// UserRepository 用户数据访问
+type UserRepository interface {
+	Create(ctx context.Context, user *User) error
+	FindByID(ctx context.Context, id uint) (*User, error)
+	FindByUsername(ctx context.Context, username string) (*User, error)
+	FindByEmail(ctx context.Context, email string) (*User, error)
+	UpdateRole(ctx context.Context, id uint, role string) error
+	UpdatePassword(ctx context.Context, id uint, hashed string) error
+	// MarkEmailVerified 记录邮箱验证时间，已经验证过的不修改
+	MarkEmailVerified(ctx context.Context, id uint, at time.Time) error
+}
+
+// PostRepository 文章数据访问，查询结果都带上作者信息(User)、分类(Category)和按名称排序的标签(Tags)
+type PostRepository interface {
+	Create(ctx context.Context, post *Post) error
+	FindByID(ctx context.Context, id uint) (*Post, error)
+	FindByIDs(ctx context.Context, ids []uint) ([]Post, error)
+	// List 按PostQuery过滤、排序、分页；为了判断是否有下一页，最多返回 PageSize+1 条，total是满足过滤条件的总数
+	List(ctx context.Context, q *PostQuery) (posts []Post, total int64, err error)
+	// Update 用changes中的非零值字段更新文章，post同步为更新后的值
+	Update(ctx context.Context, post *Post, changes Post) error
+	Delete(ctx context.Context, post *Post) error
+	// ReplaceTags 把文章的标签整体替换为tags（标签需已存在），tags为空表示清空
+	ReplaceTags(ctx context.Context, post *Post, tags []Tag) error
+	// SetCategory 设置文章分类，categoryID为nil表示取消分类
+	SetCategory(ctx context.Context, post *Post, categoryID *uint) error
+	// SetStatus 保存文章的 Status/PublishAt/PublishedAt，nil值也会写入
+	SetStatus(ctx context.Context, post *Post) error
+	// PublishDue 把 publish_at 不晚于now的定时发布文章改为已发布，返回发布的篇数
+	PublishDue(ctx context.Context, now time.Time) (int64, error)
+}
+
+// CommentRepository 评论数据访问，查询结果都带上作者信息(User)
+type CommentRepository interface {
+	Create(ctx context.Context, comment *Comment) error
+	FindByID(ctx context.Context, id uint) (*Comment, error)
+	// ListByPost 按创建顺序返回文章的全部评论，包含已软删除的（DeletedAt有值），由调用方渲染成墓碑
+	ListByPost(ctx context.Context, postID uint) ([]Comment, error)
+	// FindByIDs 额外带上所属文章(Post)，所属文章已删除的评论不返回
+	FindByIDs(ctx context.Context, ids []uint) ([]Comment, error)
+	// Update 修改评论内容，comment同步为更新后的值
+	Update(ctx context.Context, comment *Comment, content string) error
+	Delete(ctx context.Context, comment *Comment) error
+}
+
+// TokenRepository refresh token和access token吊销列表的数据访问
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *RefreshToken) error
+	FindRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	FindRefreshTokenByAccessJTI(ctx context.Context, jti string) (*RefreshToken, error)
+	// MarkRefreshTokenUsed 原子地把未使用的refresh token标记为已使用，返回false表示已经被用过
+	MarkRefreshTokenUsed(ctx context.Context, id uint, at time.Time) (bool, error)
+	// RevokeFamily 吊销整个令牌族：refresh token全部作废，未过期的access token加入吊销列表
+	RevokeFamily(ctx context.Context, familyID string, accessTTL time.Duration) error
+	// RevokeUserTokens 吊销用户全部的令牌族，重置密码后让所有已登录的设备下线
+	RevokeUserTokens(ctx context.Context, userID uint, accessTTL time.Duration) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+	// PruneRevokedTokens 清理过期时间早于before的吊销记录
+	PruneRevokedTokens(ctx context.Context, before time.Time) error
+}
+
+// TagRepository 标签和分类数据访问，名称都是规范化之后的
+type TagRepository interface {
+	// FindOrCreateTags 按名称查找标签，不存在的自动创建，结果按名称排序
+	FindOrCreateTags(ctx context.Context, names []string) ([]Tag, error)
+	FindOrCreateCategory(ctx context.Context, name string) (*Category, error)
+	// ListTags 返回至少有一篇已发布文章的标签及文章数，按文章数倒序、名称正序
+	ListTags(ctx context.Context) ([]TagCount, error)
+	// ListCategories 同ListTags
+	ListCategories(ctx context.Context) ([]CategoryCount, error)
+}
+
+// AttachmentRepository 附件数据访问
+type AttachmentRepository interface {
+	// Create 保存附件，同一个用户已有同样内容的附件时返回ErrDuplicate
+	Create(ctx context.Context, a *Attachment) error
+	FindByID(ctx context.Context, id uint) (*Attachment, error)
+	FindByUserHash(ctx context.Context, userID uint, hash string) (*Attachment, error)
+	// HashInUse 是否还有附件引用这个内容哈希，用来决定存储里的文件能不能删
+	HashInUse(ctx context.Context, hash string) (bool, error)
+	SetPost(ctx context.Context, a *Attachment, postID *uint) error
+	// ListByPost 按上传顺序返回文章的附件
+	ListByPost(ctx context.Context, postID uint) ([]Attachment, error)
+	Delete(ctx context.Context, a *Attachment) error
+}
+
+// RevisionRepository 文章修订历史数据访问，查询结果都带上修改人(Editor)
+type RevisionRepository interface {
+	// Create 保存一个版本，版本号自动取该文章当前最大版本号+1；CreatedAt为零值时取当前时间
+	Create(ctx context.Context, rev *PostRevision) error
+	// Latest 返回文章最新的版本，没有任何版本时返回ErrNotFound
+	Latest(ctx context.Context, postID uint) (*PostRevision, error)
+	Find(ctx context.Context, postID uint, rev int) (*PostRevision, error)
+	// ListByPost 按版本号倒序返回文章的全部版本
+	ListByPost(ctx context.Context, postID uint) ([]PostRevision, error)
+}
+
+// AuditRepository 审计日志数据访问，只增不改
+type AuditRepository interface {
+	Create(ctx context.Context, entry *AuditLog) error
+	// List 按时间倒序分页查询，total是满足过滤条件的总数
+	List(ctx context.Context, q *AuditQuery) (logs []AuditLog, total int64, err error)
+}
+
+// ReactionRepository 表态数据访问
+type ReactionRepository interface {
+	// Toggle 用户还没有这个表态时加上，已经有时删除；返回true表示加上了
+	Toggle(ctx context.Context, reaction *Reaction) (added bool, err error)
+	// Summaries 统计一批目标各种表态的数量，viewerID不为0时标出这个用户表过的态；没有任何表态的目标不在结果里
+	Summaries(ctx context.Context, targetType string, ids []uint, viewerID uint) (map[uint][]ReactionCount, error)
+}
+
+// FollowRepository 关注关系数据访问
+type FollowRepository interface {
+	// Follow 关注，已经关注过时返回false
+	Follow(ctx context.Context, followerID, followeeID uint) (created bool, err error)
+	// Unfollow 取消关注，本来就没有关注时返回false
+	Unfollow(ctx context.Context, followerID, followeeID uint) (removed bool, err error)
+	// ListFollowers 按关注时间倒序分页列出userID的粉丝，已删除的用户不返回
+	ListFollowers(ctx context.Context, userID uint, q *FollowQuery) (users []FollowView, total int64, err error)
+	// ListFollowing 同ListFollowers，列出userID关注的人
+	ListFollowing(ctx context.Context, userID uint, q *FollowQuery) (users []FollowView, total int64, err error)
+}
+
+// NotificationRepository 通知和通知偏好数据访问
+type NotificationRepository interface {
+	Create(ctx context.Context, notifications []Notification) error
+	// List 按时间倒序分页列出用户的通知，带上触发人(Actor)，total是满足过滤条件的总数
+	List(ctx context.Context, userID uint, q *NotificationQuery) (notifications []Notification, total int64, err error)
+	// UnreadCounts 每个类别未读的数量，没有未读的类别不在结果里
+	UnreadCounts(ctx context.Context, userID uint) (map[string]int64, error)
+	// MarkRead 把用户的一条通知标记为已读，已读的不修改；通知不存在或不属于该用户时返回ErrNotFound
+	MarkRead(ctx context.Context, userID, id uint, at time.Time) error
+	// MarkAllRead 把用户全部未读的通知标记为已读，返回标记的条数
+	MarkAllRead(ctx context.Context, userID uint, at time.Time) (int64, error)
+	// MutedTypes 用户屏蔽的通知类别
+	MutedTypes(ctx context.Context, userID uint) (map[string]bool, error)
+	// SetMuted 保存用户每个类别是否屏蔽，muted里没有的类别恢复通知
+	SetMuted(ctx context.Context, userID uint, muted map[string]bool) error
+}
+
+// AccountTokenRepository 邮箱验证和重置密码令牌的数据访问
+type AccountTokenRepository interface {
+	Create(ctx context.Context, token *AccountToken) error
+	// Consume 原子地把未使用、未过期的令牌标记为已使用，令牌不存在、已使用或已过期时返回ErrNotFound
+	Consume(ctx context.Context, hash, purpose string, at time.Time) (*AccountToken, error)
+	// Invalidate 作废用户某种用途全部未使用的令牌
+	Invalidate(ctx context.Context, userID uint, purpose string, at time.Time) error
+}
+
+// PersonalTokenRepository 个人访问令牌数据访问
+type PersonalTokenRepository interface {
+	Create(ctx context.Context, token *PersonalAccessToken) error
+	// FindByHash 按令牌哈希查找，已吊销和已过期的也返回，由调用方判断
+	FindByHash(ctx context.Context, hash string) (*PersonalAccessToken, error)
+	// ListByUser 按创建时间倒序列出用户没有吊销的令牌
+	ListByUser(ctx context.Context, userID uint) ([]PersonalAccessToken, error)
+	// Revoke 吊销用户的一个令牌，令牌不存在、不属于该用户或已经吊销时返回ErrNotFound
+	Revoke(ctx context.Context, userID, id uint, at time.Time) error
+	// RevokeAll 吊销用户全部没有吊销的令牌，重置密码时用
+	RevokeAll(ctx context.Context, userID uint, at time.Time) error
+	// Touch 记录令牌最近一次使用的时间
+	Touch(ctx context.Context, id uint, at time.Time) error
+}
+
+// HealthChecker 存储的健康检查，/readyz 使用
+type HealthChecker interface {
+	// Ready 数据库能连上并且表结构已经是最新时返回nil
+	Ready(ctx context.Context) error
+}
+
+// Repositories 所有仓储的集合，作为依赖一次性注入Server
+type Repositories struct {
+	Users          UserRepository
+	Posts          PostRepository
+	Comments       CommentRepository
+	Tokens         TokenRepository
+	Audits         AuditRepository
+	Tags           TagRepository
+	Revisions      RevisionRepository
+	Attachments    AttachmentRepository
+	Reactions      ReactionRepository
+	Follows        FollowRepository
+	Notifications  NotificationRepository
+	AccountTokens  AccountTokenRepository
+	PersonalTokens PersonalTokenRepository
+	Health         HealthChecker
+}
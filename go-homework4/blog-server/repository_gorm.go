@@ -0,0 +1,851 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ====================== 数据访问层：GORM实现 ======================
+
+// NewGormRepositories 基于同一个数据库连接创建所有仓储
+func NewGormRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Users:          &gormUserRepository{db},
+		Posts:          &gormPostRepository{db},
+		Comments:       &gormCommentRepository{db},
+		Tokens:         &gormTokenRepository{db},
+		Audits:         &gormAuditRepository{db},
+		Tags:           &gormTagRepository{db},
+		Revisions:      &gormRevisionRepository{db},
+		Attachments:    &gormAttachmentRepository{db},
+		Reactions:      &gormReactionRepository{db},
+		Follows:        &gormFollowRepository{db},
+		Notifications:  &gormNotificationRepository{db},
+		AccountTokens:  &gormAccountTokenRepository{db},
+		PersonalTokens: &gormPersonalTokenRepository{db},
+		Health:         &gormHealthChecker{db},
+	}
+}
+
+// translateError 把GORM和数据库驱动的错误转换成仓储层统一的错误
+// 唯一约束冲突直接解析驱动的错误，这样能知道是哪一列冲突，比如注册时是用户名还是邮箱重复
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	var sqliteErr sqlite3.Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation:
+		return &DuplicateError{Table: pgErr.TableName, Field: pgDuplicateColumns(pgErr.Detail)}
+	case errors.As(err, &sqliteErr) && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey):
+		return sqliteDuplicate(sqliteErr.Error())
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	}
+	return err
+}
+
+// pgUniqueViolation PostgreSQL唯一约束冲突的错误码
+const pgUniqueViolation = "23505"
+
+// pgDuplicateKey 从 "Key (username)=(bob) already exists." 里取出列名
+var pgDuplicateKey = regexp.MustCompile(`^Key \((.+?)\)=`)
+
+func pgDuplicateColumns(detail string) string {
+	m := pgDuplicateKey.FindStringSubmatch(detail)
+	if m == nil {
+		return ""
+	}
+	return strings.ReplaceAll(m[1], " ", "")
+}
+
+// sqliteDuplicate 解析 "UNIQUE constraint failed: users.username, users.email" 这样的错误信息
+func sqliteDuplicate(msg string) *DuplicateError {
+	e := &DuplicateError{}
+	_, cols, ok := strings.Cut(msg, "constraint failed: ")
+	if !ok {
+		return e
+	}
+	var fields []string
+	for _, col := range strings.Split(cols, ", ") {
+		table, field, _ := strings.Cut(col, ".")
+		e.Table = table
+		fields = append(fields, field)
+	}
+	e.Field = strings.Join(fields, ",")
+	return e
+}
+
+// ---------------------- 用户 ----------------------
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepository) Create(ctx context.Context, user *User) error {
+	return translateError(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r *gormUserRepository) FindByID(ctx context.Context, id uint) (*User, error) {
+	var user User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByUsername(ctx context.Context, username string) (*User, error) {
+	var user User
+	if err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) FindByEmail(ctx context.Context, email string) (*User, error) {
+	var user User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) UpdateRole(ctx context.Context, id uint, role string) error {
+	res := r.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Update("role", role)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return translateError(res.Error)
+}
+
+func (r *gormUserRepository) UpdatePassword(ctx context.Context, id uint, hashed string) error {
+	res := r.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Update("password", hashed)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return translateError(res.Error)
+}
+
+func (r *gormUserRepository) MarkEmailVerified(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", at).Error
+}
+
+// ---------------------- 文章 ----------------------
+
+type gormPostRepository struct {
+	db *gorm.DB
+}
+
+// withRelations 查询文章时带上作者、分类和按名称排序的标签
+func withRelations(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("User").Preload("Category").Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tags.name")
+	})
+}
+
+func (r *gormPostRepository) Create(ctx context.Context, post *Post) error {
+	return translateError(r.db.WithContext(ctx).Create(post).Error)
+}
+
+func (r *gormPostRepository) FindByID(ctx context.Context, id uint) (*Post, error) {
+	var post Post
+	// Preload("User") 关联查询作者信息
+	if err := withRelations(r.db.WithContext(ctx)).Where("id = ?", id).First(&post).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &post, nil
+}
+
+func (r *gormPostRepository) FindByIDs(ctx context.Context, ids []uint) ([]Post, error) {
+	var posts []Post
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	err := withRelations(r.db.WithContext(ctx)).Where("id IN ?", ids).Find(&posts).Error
+	return posts, translateError(err)
+}
+
+func (r *gormPostRepository) List(ctx context.Context, q *PostQuery) ([]Post, int64, error) {
+	tx := r.db.WithContext(ctx)
+
+	// 先统计满足过滤条件的总数
+	var total int64
+	if err := q.applyFilters(tx.Model(&Post{})).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var posts []Post
+	// Preload("User") 关联查询：查询文章的同时，查询文章的作者信息
+	if err := q.applyPage(q.applyFilters(withRelations(tx))).Find(&posts).Error; err != nil {
+		return nil, 0, err
+	}
+	return posts, total, nil
+}
+
+func (r *gormPostRepository) Update(ctx context.Context, post *Post, changes Post) error {
+	return translateError(r.db.WithContext(ctx).Model(post).Updates(changes).Error)
+}
+
+func (r *gormPostRepository) Delete(ctx context.Context, post *Post) error {
+	return translateError(r.db.WithContext(ctx).Delete(post).Error)
+}
+
+func (r *gormPostRepository) SetStatus(ctx context.Context, post *Post) error {
+	// 用map更新，PublishAt等nil值也会写成NULL
+	return translateError(r.db.WithContext(ctx).Model(post).Updates(map[string]interface{}{
+		"status":       post.Status,
+		"publish_at":   post.PublishAt,
+		"published_at": post.PublishedAt,
+	}).Error)
+}
+
+func (r *gormPostRepository) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+	// 第一次发布的时间记为预定的发布时间
+	res := r.db.WithContext(ctx).Model(&Post{}).
+		Where("status = ? AND publish_at <= ?", StatusScheduled, now).
+		Updates(map[string]interface{}{
+			"status":       StatusPublished,
+			"published_at": gorm.Expr("COALESCE(published_at, publish_at)"),
+			"publish_at":   nil,
+		})
+	return res.RowsAffected, res.Error
+}
+
+func (r *gormPostRepository) ReplaceTags(ctx context.Context, post *Post, tags []Tag) error {
+	assoc := r.db.WithContext(ctx).Model(post).Association("Tags")
+	if len(tags) == 0 {
+		err := assoc.Clear()
+		post.Tags = []Tag{} // Clear会把post.Tags置为nil，保持和查询结果一致返回空数组
+		return err
+	}
+	return assoc.Replace(tags)
+}
+
+func (r *gormPostRepository) SetCategory(ctx context.Context, post *Post, categoryID *uint) error {
+	return translateError(r.db.WithContext(ctx).Model(post).Update("category_id", categoryID).Error)
+}
+
+// ---------------------- 评论 ----------------------
+
+type gormCommentRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormCommentRepository) Create(ctx context.Context, comment *Comment) error {
+	return translateError(r.db.WithContext(ctx).Create(comment).Error)
+}
+
+func (r *gormCommentRepository) FindByID(ctx context.Context, id uint) (*Comment, error) {
+	var comment Comment
+	if err := r.db.WithContext(ctx).Preload("User").Where("id = ?", id).First(&comment).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &comment, nil
+}
+
+func (r *gormCommentRepository) ListByPost(ctx context.Context, postID uint) ([]Comment, error) {
+	var comments []Comment
+	// Preload("User") 关联查询评论的作者信息；Unscoped带上已删除的评论
+	err := r.db.WithContext(ctx).Unscoped().Preload("User").Where("post_id = ?", postID).Order("id").Find(&comments).Error
+	return comments, translateError(err)
+}
+
+func (r *gormCommentRepository) FindByIDs(ctx context.Context, ids []uint) ([]Comment, error) {
+	var comments []Comment
+	if len(ids) == 0 {
+		return comments, nil
+	}
+	// Joins会带上Post的软删除条件，已删除文章下的评论查不出来
+	err := r.db.WithContext(ctx).Preload("User").Joins("Post").Where("comments.id IN ?", ids).Find(&comments).Error
+	return comments, translateError(err)
+}
+
+func (r *gormCommentRepository) Update(ctx context.Context, comment *Comment, content string) error {
+	return translateError(r.db.WithContext(ctx).Model(comment).Update("content", content).Error)
+}
+
+func (r *gormCommentRepository) Delete(ctx context.Context, comment *Comment) error {
+	return translateError(r.db.WithContext(ctx).Delete(comment).Error)
+}
+
+// ---------------------- 令牌 ----------------------
+
+type gormTokenRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormTokenRepository) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	return translateError(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r *gormTokenRepository) FindRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	var rt RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&rt).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &rt, nil
+}
+
+func (r *gormTokenRepository) FindRefreshTokenByAccessJTI(ctx context.Context, jti string) (*RefreshToken, error) {
+	var rt RefreshToken
+	if err := r.db.WithContext(ctx).Where("access_jti = ?", jti).First(&rt).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &rt, nil
+}
+
+func (r *gormTokenRepository) MarkRefreshTokenUsed(ctx context.Context, id uint, at time.Time) (bool, error) {
+	// 条件更新防止并发请求同时用同一个令牌刷新成功
+	res := r.db.WithContext(ctx).Model(&RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", at)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *gormTokenRepository) RevokeFamily(ctx context.Context, familyID string, accessTTL time.Duration) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tokens []RefreshToken
+		if err := tx.Where("family_id = ?", familyID).Find(&tokens).Error; err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			// access token的过期时间以签发时间推算
+			if err := revokeAccessToken(tx, t.AccessJTI, t.CreatedAt.Add(accessTTL)); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+func (r *gormTokenRepository) RevokeUserTokens(ctx context.Context, userID uint, accessTTL time.Duration) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 只有最近accessTTL内签发的access token还没过期，需要加入吊销列表
+		var tokens []RefreshToken
+		if err := tx.Where("user_id = ? AND created_at > ?", userID, time.Now().Add(-accessTTL)).Find(&tokens).Error; err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			if err := revokeAccessToken(tx, t.AccessJTI, t.CreatedAt.Add(accessTTL)); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+func (r *gormTokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return revokeAccessToken(r.db.WithContext(ctx), jti, expiresAt)
+}
+
+// revokeAccessToken 把access token的jti加入吊销列表
+func revokeAccessToken(tx *gorm.DB, jti string, expiresAt time.Time) error {
+	if jti == "" || expiresAt.Before(time.Now()) {
+		return nil // 已经过期的令牌不需要再记录
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+func (r *gormTokenRepository) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormTokenRepository) PruneRevokedTokens(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&RevokedToken{}).Error
+}
+
+// ---------------------- 审计日志 ----------------------
+
+type gormAuditRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAuditRepository) Create(ctx context.Context, entry *AuditLog) error {
+	return translateError(r.db.WithContext(ctx).Create(entry).Error)
+}
+
+func (r *gormAuditRepository) List(ctx context.Context, q *AuditQuery) ([]AuditLog, int64, error) {
+	tx := r.db.WithContext(ctx).Model(&AuditLog{})
+	if q.ActorID != 0 {
+		tx = tx.Where("actor_id = ?", q.ActorID)
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []AuditLog
+	err := tx.Order("id DESC").Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).Find(&logs).Error
+	return logs, total, err
+}
+
+// ---------------------- 标签和分类 ----------------------
+
+type gormTagRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormTagRepository) FindOrCreateTags(ctx context.Context, names []string) ([]Tag, error) {
+	tags := []Tag{}
+	if len(names) == 0 {
+		return tags, nil
+	}
+	tx := r.db.WithContext(ctx)
+	// 已存在的名称跳过，并发创建同名标签也不会报错
+	create := make([]Tag, len(names))
+	for i, name := range names {
+		create[i] = Tag{Name: name}
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&create).Error; err != nil {
+		return nil, translateError(err)
+	}
+	err := tx.Where("name IN ?", names).Order("name").Find(&tags).Error
+	return tags, translateError(err)
+}
+
+func (r *gormTagRepository) FindOrCreateCategory(ctx context.Context, name string) (*Category, error) {
+	tx := r.db.WithContext(ctx)
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Category{Name: name}).Error; err != nil {
+		return nil, translateError(err)
+	}
+	var category Category
+	if err := tx.Where("name = ?", name).First(&category).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &category, nil
+}
+
+func (r *gormTagRepository) ListTags(ctx context.Context) ([]TagCount, error) {
+	tags := []TagCount{}
+	// 只统计已发布且未删除的文章
+	err := r.db.WithContext(ctx).Model(&Tag{}).
+		Select("tags.id, tags.name, COUNT(posts.id) AS post_count").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL AND posts.status = ?", StatusPublished).
+		Group("tags.id, tags.name").
+		Order("post_count DESC, tags.name").
+		Scan(&tags).Error
+	return tags, err
+}
+
+func (r *gormTagRepository) ListCategories(ctx context.Context) ([]CategoryCount, error) {
+	categories := []CategoryCount{}
+	err := r.db.WithContext(ctx).Model(&Category{}).
+		Select("categories.id, categories.name, COUNT(posts.id) AS post_count").
+		Joins("JOIN posts ON posts.category_id = categories.id AND posts.deleted_at IS NULL AND posts.status = ?", StatusPublished).
+		Group("categories.id, categories.name").
+		Order("post_count DESC, categories.name").
+		Scan(&categories).Error
+	return categories, err
+}
+
+// ---------------------- 文章修订历史 ----------------------
+
+type gormRevisionRepository struct {
+	db *gorm.DB
+}
+
+// revisionCreateAttempts 保存版本时版本号冲突最多重试的次数
+const revisionCreateAttempts = 5
+
+func (r *gormRevisionRepository) Create(ctx context.Context, rev *PostRevision) error {
+	// 取最大版本号和插入放在一个事务里；并发修改同一篇文章时两边可能取到同一个版本号，
+	// 后插入的违反(post_id, rev)唯一索引，重新取版本号再插入，保证每次修改都留下一个版本
+	var err error
+	for i := 0; i < revisionCreateAttempts; i++ {
+		rev.ID = 0
+		err = translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var max int
+			if err := tx.Model(&PostRevision{}).Where("post_id = ?", rev.PostID).
+				Select("COALESCE(MAX(rev), 0)").Scan(&max).Error; err != nil {
+				return err
+			}
+			rev.Rev = max + 1
+			return tx.Create(rev).Error
+		}))
+		if !errors.Is(err, ErrDuplicate) {
+			return err
+		}
+	}
+	return err
+}
+
+func (r *gormRevisionRepository) Latest(ctx context.Context, postID uint) (*PostRevision, error) {
+	var rev PostRevision
+	err := r.db.WithContext(ctx).Preload("Editor").Where("post_id = ?", postID).Order("rev DESC").First(&rev).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &rev, nil
+}
+
+func (r *gormRevisionRepository) Find(ctx context.Context, postID uint, rev int) (*PostRevision, error) {
+	var pr PostRevision
+	err := r.db.WithContext(ctx).Preload("Editor").Where("post_id = ? AND rev = ?", postID, rev).First(&pr).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &pr, nil
+}
+
+func (r *gormRevisionRepository) ListByPost(ctx context.Context, postID uint) ([]PostRevision, error) {
+	revs := []PostRevision{}
+	err := r.db.WithContext(ctx).Preload("Editor").Where("post_id = ?", postID).Order("rev DESC").Find(&revs).Error
+	return revs, translateError(err)
+}
+
+// ---------------------- 附件 ----------------------
+
+type gormAttachmentRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAttachmentRepository) Create(ctx context.Context, a *Attachment) error {
+	return translateError(r.db.WithContext(ctx).Create(a).Error)
+}
+
+func (r *gormAttachmentRepository) FindByID(ctx context.Context, id uint) (*Attachment, error) {
+	var a Attachment
+	if err := r.db.WithContext(ctx).First(&a, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &a, nil
+}
+
+func (r *gormAttachmentRepository) FindByUserHash(ctx context.Context, userID uint, hash string) (*Attachment, error) {
+	var a Attachment
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND hash = ?", userID, hash).First(&a).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &a, nil
+}
+
+func (r *gormAttachmentRepository) HashInUse(ctx context.Context, hash string) (bool, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&Attachment{}).Where("hash = ?", hash).Limit(1).Count(&n).Error
+	return n > 0, translateError(err)
+}
+
+func (r *gormAttachmentRepository) SetPost(ctx context.Context, a *Attachment, postID *uint) error {
+	if err := r.db.WithContext(ctx).Model(a).Update("post_id", postID).Error; err != nil {
+		return translateError(err)
+	}
+	a.PostID = postID
+	return nil
+}
+
+func (r *gormAttachmentRepository) ListByPost(ctx context.Context, postID uint) ([]Attachment, error) {
+	attachments := []Attachment{}
+	err := r.db.WithContext(ctx).Where("post_id = ?", postID).Order("id").Find(&attachments).Error
+	return attachments, translateError(err)
+}
+
+func (r *gormAttachmentRepository) Delete(ctx context.Context, a *Attachment) error {
+	return translateError(r.db.WithContext(ctx).Delete(a).Error)
+}
+
+// ---------------------- 表态 ----------------------
+
+type gormReactionRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormReactionRepository) Toggle(ctx context.Context, reaction *Reaction) (bool, error) {
+	added := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ? AND target_type = ? AND target_id = ? AND kind = ?",
+			reaction.UserID, reaction.TargetType, reaction.TargetID, reaction.Kind).Delete(&Reaction{})
+		if res.Error != nil || res.RowsAffected > 0 {
+			return res.Error
+		}
+		added = true
+		return tx.Create(reaction).Error
+	})
+	err = translateError(err)
+	if errors.Is(err, ErrDuplicate) {
+		// 同一个用户并发点了两次，另一个请求已经加上了
+		return true, nil
+	}
+	return added, err
+}
+
+func (r *gormReactionRepository) Summaries(ctx context.Context, targetType string, ids []uint, viewerID uint) (map[uint][]ReactionCount, error) {
+	var tallies []reactionTally
+	err := r.db.WithContext(ctx).Model(&Reaction{}).
+		Select("target_id, kind, COUNT(*) AS count").
+		Where("target_type = ? AND target_id IN ?", targetType, ids).
+		Group("target_id, kind").
+		Scan(&tallies).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	mine := make(map[uint]map[string]bool)
+	if viewerID != 0 && len(tallies) > 0 {
+		var own []Reaction
+		err := r.db.WithContext(ctx).Select("target_id, kind").
+			Where("user_id = ? AND target_type = ? AND target_id IN ?", viewerID, targetType, ids).
+			Find(&own).Error
+		if err != nil {
+			return nil, translateError(err)
+		}
+		for _, x := range own {
+			if mine[x.TargetID] == nil {
+				mine[x.TargetID] = make(map[string]bool)
+			}
+			mine[x.TargetID][x.Kind] = true
+		}
+	}
+	return groupReactions(tallies, mine), nil
+}
+
+// ---------------------- 关注 ----------------------
+
+type gormFollowRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormFollowRepository) Follow(ctx context.Context, followerID, followeeID uint) (bool, error) {
+	err := translateError(r.db.WithContext(ctx).Create(&Follow{FollowerID: followerID, FolloweeID: followeeID}).Error)
+	if errors.Is(err, ErrDuplicate) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *gormFollowRepository) Unfollow(ctx context.Context, followerID, followeeID uint) (bool, error) {
+	res := r.db.WithContext(ctx).Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Delete(&Follow{})
+	return res.RowsAffected > 0, translateError(res.Error)
+}
+
+func (r *gormFollowRepository) ListFollowers(ctx context.Context, userID uint, q *FollowQuery) ([]FollowView, int64, error) {
+	return r.list(ctx, "followee_id", "follower_id", userID, q)
+}
+
+func (r *gormFollowRepository) ListFollowing(ctx context.Context, userID uint, q *FollowQuery) ([]FollowView, int64, error) {
+	return r.list(ctx, "follower_id", "followee_id", userID, q)
+}
+
+// list 按keyCol=userID查关注关系，列出userCol那一边的用户
+func (r *gormFollowRepository) list(ctx context.Context, keyCol, userCol string, userID uint, q *FollowQuery) ([]FollowView, int64, error) {
+	base := func() *gorm.DB {
+		return r.db.WithContext(ctx).Table("follows").
+			Joins("JOIN users ON users.id = follows."+userCol+" AND users.deleted_at IS NULL").
+			Where("follows."+keyCol+" = ?", userID)
+	}
+	var total int64
+	if err := base().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	users := []FollowView{}
+	err := base().Select("users.id, users.username, follows.created_at AS followed_at").
+		Order("follows.id DESC").Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Scan(&users).Error
+	return users, total, err
+}
+
+// ---------------------- 通知 ----------------------
+
+type gormNotificationRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormNotificationRepository) Create(ctx context.Context, notifications []Notification) error {
+	return translateError(r.db.WithContext(ctx).Omit("Actor").Create(&notifications).Error)
+}
+
+func (r *gormNotificationRepository) List(ctx context.Context, userID uint, q *NotificationQuery) ([]Notification, int64, error) {
+	tx := r.db.WithContext(ctx).Model(&Notification{}).Where("user_id = ?", userID)
+	if q.Unread {
+		tx = tx.Where("read_at IS NULL")
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	notifications := []Notification{}
+	err := tx.Preload("Actor").Order("id DESC").Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).Find(&notifications).Error
+	return notifications, total, err
+}
+
+func (r *gormNotificationRepository) UnreadCounts(ctx context.Context, userID uint) (map[string]int64, error) {
+	var rows []struct {
+		Type  string
+		Count int64
+	}
+	err := r.db.WithContext(ctx).Model(&Notification{}).Select("type, COUNT(*) AS count").
+		Where("user_id = ? AND read_at IS NULL", userID).Group("type").Scan(&rows).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Type] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *gormNotificationRepository) MarkRead(ctx context.Context, userID, id uint, at time.Time) error {
+	res := r.db.WithContext(ctx).Model(&Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", id, userID).Update("read_at", at)
+	if res.Error != nil || res.RowsAffected > 0 {
+		return translateError(res.Error)
+	}
+	// 没有更新到：要么已经读过，要么不是这个用户的通知
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&Notification{}).Where("id = ? AND user_id = ?", id, userID).Count(&n).Error; err != nil {
+		return translateError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormNotificationRepository) MarkAllRead(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", at)
+	return res.RowsAffected, translateError(res.Error)
+}
+
+func (r *gormNotificationRepository) MutedTypes(ctx context.Context, userID uint) (map[string]bool, error) {
+	var types []string
+	err := r.db.WithContext(ctx).Model(&NotificationPreference{}).
+		Where("user_id = ? AND muted = ?", userID, true).Pluck("type", &types).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	muted := make(map[string]bool, len(types))
+	for _, typ := range types {
+		muted[typ] = true
+	}
+	return muted, nil
+}
+
+func (r *gormNotificationRepository) SetMuted(ctx context.Context, userID uint, muted map[string]bool) error {
+	prefs := make([]NotificationPreference, len(NotificationTypes))
+	for i, typ := range NotificationTypes {
+		prefs[i] = NotificationPreference{UserID: userID, Type: typ, Muted: muted[typ]}
+	}
+	return translateError(r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"muted", "updated_at"}),
+	}).Create(&prefs).Error)
+}
+
+// ---------------------- 邮箱验证和重置密码令牌 ----------------------
+
+type gormAccountTokenRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAccountTokenRepository) Create(ctx context.Context, token *AccountToken) error {
+	return translateError(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r *gormAccountTokenRepository) Consume(ctx context.Context, hash, purpose string, at time.Time) (*AccountToken, error) {
+	var token AccountToken
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证同一个令牌并发使用时只有一次成功
+		res := tx.Model(&AccountToken{}).
+			Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", hash, purpose, at).
+			Update("used_at", at)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Where("token_hash = ?", hash).First(&token).Error
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+func (r *gormAccountTokenRepository) Invalidate(ctx context.Context, userID uint, purpose string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&AccountToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", at).Error
+}
+
+// ---------------------- 个人访问令牌 ----------------------
+
+type gormPersonalTokenRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormPersonalTokenRepository) Create(ctx context.Context, token *PersonalAccessToken) error {
+	return translateError(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r *gormPersonalTokenRepository) FindByHash(ctx context.Context, hash string) (*PersonalAccessToken, error) {
+	var token PersonalAccessToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+func (r *gormPersonalTokenRepository) ListByUser(ctx context.Context, userID uint) ([]PersonalAccessToken, error) {
+	tokens := []PersonalAccessToken{}
+	err := r.db.WithContext(ctx).Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC, id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *gormPersonalTokenRepository) Revoke(ctx context.Context, userID, id uint, at time.Time) error {
+	res := r.db.WithContext(ctx).Model(&PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", at)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return res.Error
+}
+
+func (r *gormPersonalTokenRepository) RevokeAll(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+}
+
+func (r *gormPersonalTokenRepository) Touch(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&PersonalAccessToken{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
+
+// ---------------------- 健康检查 ----------------------
+
+type gormHealthChecker struct{ db *gorm.DB }
+
+func (h *gormHealthChecker) Ready(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("数据库连接失败: %w", err)
+	}
+	m, err := NewMigrator(h.db)
+	if err != nil {
+		return err
+	}
+	return m.Check(ctx)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// sqliteError 在SQLite里真的违反一次唯一约束，拿到驱动返回的原始错误
+func sqliteError(t *testing.T, schema, insert string) error {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.DSN = t.TempDir() + "/dup.db"
+	db, err := openDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(insert).Error; err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(insert).Error
+	if err == nil {
+		t.Fatal("重复插入没有报错")
+	}
+	return err
+}
+
+func TestTranslateDuplicateError(t *testing.T) {
+	cases := []struct {
+		name         string
+		err          error
+		table, field string
+		code         string // 注册接口返回的错误码
+	}{
+		{"pg用户名", &pgconn.PgError{Code: "23505", TableName: "users", Detail: "Key (username)=(bob) already exists."},
+			"users", "username", errUsernameTaken.Code},
+		{"pg邮箱", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505", TableName: "users", Detail: "Key (email)=(bob@example.com) already exists."}),
+			"users", "email", errEmailTaken.Code},
+		{"pg联合唯一", &pgconn.PgError{Code: "23505", TableName: "users", Detail: "Key (username, email)=(bob, bob@example.com) already exists."},
+			"users", "username,email", errUserExists.Code},
+		{"pg解析不出列名", &pgconn.PgError{Code: "23505", TableName: "users"},
+			"users", "", errUserExists.Code},
+		{"sqlite用户名", sqliteError(t, "CREATE TABLE users (username text UNIQUE)", "INSERT INTO users VALUES ('bob')"),
+			"users", "username", errUsernameTaken.Code},
+		{"sqlite联合唯一", sqliteError(t, "CREATE TABLE users (username text, email text, UNIQUE (username, email))", "INSERT INTO users VALUES ('bob', 'b@x')"),
+			"users", "username,email", errUserExists.Code},
+		{"sqlite主键", sqliteError(t, "CREATE TABLE users (id integer PRIMARY KEY)", "INSERT INTO users VALUES (1)"),
+			"users", "id", errUserExists.Code},
+	}
+	for _, tc := range cases {
+		err := translateError(tc.err)
+		var dup *DuplicateError
+		if !errors.As(err, &dup) || !errors.Is(err, ErrDuplicate) {
+			t.Errorf("%s: 转换成 %v，期望 DuplicateError", tc.name, err)
+			continue
+		}
+		if dup.Table != tc.table || dup.Field != tc.field {
+			t.Errorf("%s: 冲突的是 %s.%s，期望 %s.%s", tc.name, dup.Table, dup.Field, tc.table, tc.field)
+		}
+
+		// 经过 fail 输出的响应是409和对应的错误码
+		s, r := specServer(t)
+		s.Users = duplicateUsers{s.Users, err}
+		resp := specClient{t, r}.call("POST", "/api/register", "", gin.H{"username": "bob", "password": "secret", "email": "bob@example.com"}, 409)
+		if code := errorCode(resp); code != tc.code {
+			t.Errorf("%s: 错误码 %s，期望 %s", tc.name, code, tc.code)
+		}
+	}
+
+	// 其它错误原样返回
+	other := &pgconn.PgError{Code: "23503"}
+	if err := translateError(other); err != other {
+		t.Errorf("外键错误被转换成 %v", err)
+	}
+	if err := translateError(nil); err != nil {
+		t.Errorf("nil 被转换成 %v", err)
+	}
+}
+
+// duplicateUsers 创建用户时总是返回给定的错误
+type duplicateUsers struct {
+	UserRepository
+	err error
+}
+
+func (d duplicateUsers) Create(context.Context, *User) error {
+	return d.err
+}
@@ -0,0 +1,1280 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ====================== 数据访问层：内存实现（单元测试用） ======================
+// 所有仓储共享同一个memoryStore，行为尽量和GORM实现保持一致：
+// 自增ID、自动填充创建/更新时间、软删除、查询时带上关联的作者和文章、查不到时返回空切片而不是nil
+
+// NewMemoryRepositories 创建一组共享数据的内存仓储
+func NewMemoryRepositories() Repositories {
+	s := &memoryStore{
+		nextID:         make(map[string]uint),
+		users:          make(map[uint]*User),
+		posts:          make(map[uint]*Post),
+		comments:       make(map[uint]*Comment),
+		refreshTokens:  make(map[uint]*RefreshToken),
+		revoked:        make(map[string]*RevokedToken),
+		audits:         []AuditLog{},
+		tags:           make(map[uint]*Tag),
+		categories:     make(map[uint]*Category),
+		postTags:       make(map[uint][]uint),
+		revisions:      make(map[uint][]PostRevision),
+		attachments:    make(map[uint]*Attachment),
+		reactions:      make(map[uint]*Reaction),
+		follows:        make(map[uint]*Follow),
+		notifications:  make(map[uint]*Notification),
+		mutedTypes:     make(map[uint]map[string]bool),
+		accountTokens:  make(map[uint]*AccountToken),
+		personalTokens: make(map[uint]*PersonalAccessToken),
+	}
+	return Repositories{
+		Users:          &memoryUserRepository{s},
+		Posts:          &memoryPostRepository{s},
+		Comments:       &memoryCommentRepository{s},
+		Tokens:         &memoryTokenRepository{s},
+		Audits:         &memoryAuditRepository{s},
+		Tags:           &memoryTagRepository{s},
+		Revisions:      &memoryRevisionRepository{s},
+		Attachments:    &memoryAttachmentRepository{s},
+		Reactions:      &memoryReactionRepository{s},
+		Follows:        &memoryFollowRepository{s},
+		Notifications:  &memoryNotificationRepository{s},
+		AccountTokens:  &memoryAccountTokenRepository{s},
+		PersonalTokens: &memoryPersonalTokenRepository{s},
+		Health:         memoryHealthChecker{},
+	}
+}
+
+type memoryStore struct {
+	mu             sync.RWMutex
+	nextID         map[string]uint // 每张表各自的自增序列
+	users          map[uint]*User
+	posts          map[uint]*Post
+	comments       map[uint]*Comment
+	refreshTokens  map[uint]*RefreshToken
+	revoked        map[string]*RevokedToken
+	audits         []AuditLog // 按写入顺序保存
+	tags           map[uint]*Tag
+	categories     map[uint]*Category
+	postTags       map[uint][]uint         // 文章ID -> 标签ID，相当于关联表post_tags
+	revisions      map[uint][]PostRevision // 文章ID -> 按版本号升序的修订
+	attachments    map[uint]*Attachment
+	reactions      map[uint]*Reaction
+	follows        map[uint]*Follow
+	notifications  map[uint]*Notification
+	mutedTypes     map[uint]map[string]bool // 用户ID -> 屏蔽的通知类别，相当于notification_preferences表
+	accountTokens  map[uint]*AccountToken
+	personalTokens map[uint]*PersonalAccessToken
+}
+
+func (s *memoryStore) newID(table string) uint {
+	s.nextID[table]++
+	return s.nextID[table]
+}
+
+// newModel 生成带ID和时间戳的gorm.Model
+func (s *memoryStore) newModel(table string) gorm.Model {
+	now := time.Now()
+	return gorm.Model{ID: s.newID(table), CreatedAt: now, UpdatedAt: now}
+}
+
+func deleted(m *gorm.Model) bool {
+	return m.DeletedAt.Valid
+}
+
+// userOf 返回作者信息的副本，调用方需持有锁
+func (s *memoryStore) userOf(id uint) User {
+	if u, ok := s.users[id]; ok && !deleted(&u.Model) {
+		return *u
+	}
+	return User{}
+}
+
+// ---------------------- 用户 ----------------------
+
+type memoryUserRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryUserRepository) Create(_ context.Context, user *User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, u := range r.s.users {
+		if u.Username == user.Username {
+			return &DuplicateError{Table: "users", Field: "username"}
+		}
+		if u.Email == user.Email {
+			return &DuplicateError{Table: "users", Field: "email"}
+		}
+	}
+	user.Model = r.s.newModel("users")
+	cp := *user
+	r.s.users[cp.ID] = &cp
+	return nil
+}
+
+func (r *memoryUserRepository) FindByID(_ context.Context, id uint) (*User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	u, ok := r.s.users[id]
+	if !ok || deleted(&u.Model) {
+		return nil, ErrNotFound
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (r *memoryUserRepository) FindByUsername(_ context.Context, username string) (*User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, u := range r.s.users {
+		if u.Username == username && !deleted(&u.Model) {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepository) FindByEmail(_ context.Context, email string) (*User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, u := range r.s.users {
+		if u.Email == email && !deleted(&u.Model) {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepository) UpdateRole(_ context.Context, id uint, role string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	u, ok := r.s.users[id]
+	if !ok || deleted(&u.Model) {
+		return ErrNotFound
+	}
+	u.Role = role
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *memoryUserRepository) UpdatePassword(_ context.Context, id uint, hashed string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	u, ok := r.s.users[id]
+	if !ok || deleted(&u.Model) {
+		return ErrNotFound
+	}
+	u.Password = hashed
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *memoryUserRepository) MarkEmailVerified(_ context.Context, id uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if u, ok := r.s.users[id]; ok && u.EmailVerifiedAt == nil {
+		u.EmailVerifiedAt = &at
+	}
+	return nil
+}
+
+// ---------------------- 文章 ----------------------
+
+type memoryPostRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryPostRepository) Create(_ context.Context, post *Post) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	post.Model = r.s.newModel("posts")
+	if post.Format == "" {
+		post.Format = FormatPlain // 与数据库的默认值一致
+	}
+	cp := *post
+	cp.User, cp.Category, cp.Tags = User{}, nil, nil
+	r.s.posts[cp.ID] = &cp
+	r.s.setPostTags(cp.ID, post.Tags)
+	return nil
+}
+
+// setPostTags 保存文章和标签的关联，调用方需持有锁
+func (s *memoryStore) setPostTags(postID uint, tags []Tag) {
+	ids := make([]uint, len(tags))
+	for i, t := range tags {
+		ids[i] = t.ID
+	}
+	s.postTags[postID] = ids
+}
+
+// load 返回带作者、分类和标签的文章副本，调用方需持有锁
+func (r *memoryPostRepository) load(p *Post) Post {
+	cp := *p
+	cp.User = r.s.userOf(p.UserID)
+	if p.CategoryID != nil {
+		if category, ok := r.s.categories[*p.CategoryID]; ok {
+			c := *category
+			cp.Category = &c
+		}
+	}
+	cp.Tags = []Tag{}
+	for _, id := range r.s.postTags[p.ID] {
+		if t, ok := r.s.tags[id]; ok {
+			cp.Tags = append(cp.Tags, *t)
+		}
+	}
+	sort.Slice(cp.Tags, func(i, j int) bool { return cp.Tags[i].Name < cp.Tags[j].Name })
+	return cp
+}
+
+// hasTag 文章是否带有指定名称的标签，调用方需持有锁
+func (s *memoryStore) hasTag(postID uint, name string) bool {
+	for _, id := range s.postTags[postID] {
+		if t, ok := s.tags[id]; ok && t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryPostRepository) FindByID(_ context.Context, id uint) (*Post, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	p, ok := r.s.posts[id]
+	if !ok || deleted(&p.Model) {
+		return nil, ErrNotFound
+	}
+	post := r.load(p)
+	return &post, nil
+}
+
+func (r *memoryPostRepository) FindByIDs(_ context.Context, ids []uint) ([]Post, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	posts := []Post{}
+	for _, id := range ids {
+		if p, ok := r.s.posts[id]; ok && !deleted(&p.Model) {
+			posts = append(posts, r.load(p))
+		}
+	}
+	return posts, nil
+}
+
+// matches 与 PostQuery.applyFilters 的过滤条件一致，调用方需持有锁
+func (r *memoryPostRepository) matches(q *PostQuery, p *Post) bool {
+	if p.Status != q.Status || (q.Status != StatusPublished && p.UserID != q.viewerID) {
+		return false
+	}
+	if q.AuthorID != 0 && p.UserID != q.AuthorID {
+		return false
+	}
+	if q.followerID != 0 && r.s.findFollow(q.followerID, p.UserID) == nil {
+		return false
+	}
+	if q.Author != "" && r.s.userOf(p.UserID).Username != q.Author {
+		return false
+	}
+	if q.start != nil && p.CreatedAt.Before(*q.start) {
+		return false
+	}
+	if q.end != nil && !p.CreatedAt.Before(*q.end) {
+		return false
+	}
+	if kw := strings.TrimSpace(q.Keyword); kw != "" &&
+		!strings.Contains(strings.ToLower(p.Title), strings.ToLower(kw)) {
+		return false
+	}
+	if q.Tag != "" && !r.s.hasTag(p.ID, q.Tag) {
+		return false
+	}
+	if q.Category != "" {
+		if p.CategoryID == nil {
+			return false
+		}
+		if category, ok := r.s.categories[*p.CategoryID]; !ok || category.Name != q.Category {
+			return false
+		}
+	}
+	return true
+}
+
+// postBefore 按排序字段和ID比较两篇文章在结果中的先后，与 PostQuery.applyPage 的排序一致
+func postBefore(q *PostQuery, a time.Time, aID uint, b time.Time, bID uint) bool {
+	if !a.Equal(b) {
+		if q.Order == "asc" {
+			return a.Before(b)
+		}
+		return a.After(b)
+	}
+	if q.Order == "asc" {
+		return aID < bID
+	}
+	return aID > bID
+}
+
+func (r *memoryPostRepository) List(_ context.Context, q *PostQuery) ([]Post, int64, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	all := []Post{}
+	for _, p := range r.s.posts {
+		if !deleted(&p.Model) && r.matches(q, p) {
+			all = append(all, r.load(p))
+		}
+	}
+	total := int64(len(all))
+	sort.Slice(all, func(i, j int) bool {
+		return postBefore(q, q.sortValue(&all[i]), all[i].ID, q.sortValue(&all[j]), all[j].ID)
+	})
+
+	start := 0
+	if q.cursor != nil {
+		// 跳过游标及之前的数据
+		for start < len(all) && !postBefore(q, q.cursor.Value, q.cursor.ID, q.sortValue(&all[start]), all[start].ID) {
+			start++
+		}
+	} else {
+		start = (q.Page - 1) * q.PageSize
+	}
+	if start > len(all) {
+		start = len(all)
+	}
+	end := start + q.PageSize + 1 // 多取一条，用来判断是否还有下一页
+	if end > len(all) {
+		end = len(all)
+	}
+	return all[start:end], total, nil
+}
+
+func (r *memoryPostRepository) Update(_ context.Context, post *Post, changes Post) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p, ok := r.s.posts[post.ID]
+	if !ok || deleted(&p.Model) {
+		return ErrNotFound
+	}
+	// 与GORM的Updates(struct)一致：只更新非零值字段
+	if changes.Title != "" {
+		p.Title = changes.Title
+	}
+	if changes.Content != "" {
+		p.Content = changes.Content
+	}
+	if changes.Format != "" {
+		p.Format = changes.Format
+	}
+	p.UpdatedAt = time.Now()
+	post.Title, post.Content, post.Format, post.UpdatedAt = p.Title, p.Content, p.Format, p.UpdatedAt
+	return nil
+}
+
+func (r *memoryPostRepository) Delete(_ context.Context, post *Post) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if p, ok := r.s.posts[post.ID]; ok {
+		p.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+func (r *memoryPostRepository) SetStatus(_ context.Context, post *Post) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p, ok := r.s.posts[post.ID]
+	if !ok || deleted(&p.Model) {
+		return ErrNotFound
+	}
+	p.Status, p.PublishAt, p.PublishedAt = post.Status, post.PublishAt, post.PublishedAt
+	p.UpdatedAt = time.Now()
+	post.UpdatedAt = p.UpdatedAt
+	return nil
+}
+
+func (r *memoryPostRepository) PublishDue(_ context.Context, now time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var n int64
+	for _, p := range r.s.posts {
+		if deleted(&p.Model) || p.Status != StatusScheduled || p.PublishAt == nil || p.PublishAt.After(now) {
+			continue
+		}
+		at := *p.PublishAt
+		p.Status, p.PublishAt, p.UpdatedAt = StatusPublished, nil, now
+		if p.PublishedAt == nil {
+			p.PublishedAt = &at
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (r *memoryPostRepository) ReplaceTags(_ context.Context, post *Post, tags []Tag) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.setPostTags(post.ID, tags)
+	post.Tags = tags
+	return nil
+}
+
+func (r *memoryPostRepository) SetCategory(_ context.Context, post *Post, categoryID *uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	p, ok := r.s.posts[post.ID]
+	if !ok || deleted(&p.Model) {
+		return ErrNotFound
+	}
+	p.CategoryID = categoryID
+	p.UpdatedAt = time.Now()
+	post.CategoryID, post.UpdatedAt = categoryID, p.UpdatedAt
+	return nil
+}
+
+// ---------------------- 评论 ----------------------
+
+type memoryCommentRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryCommentRepository) Create(_ context.Context, comment *Comment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	comment.Model = r.s.newModel("comments")
+	cp := *comment
+	cp.User, cp.Post = User{}, Post{}
+	r.s.comments[cp.ID] = &cp
+	return nil
+}
+
+func (r *memoryCommentRepository) FindByID(_ context.Context, id uint) (*Comment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	cm, ok := r.s.comments[id]
+	if !ok || deleted(&cm.Model) {
+		return nil, ErrNotFound
+	}
+	cp := *cm
+	cp.User = r.s.userOf(cm.UserID)
+	return &cp, nil
+}
+
+func (r *memoryCommentRepository) ListByPost(_ context.Context, postID uint) ([]Comment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	comments := []Comment{}
+	for _, cm := range r.s.comments {
+		if cm.PostID == postID {
+			cp := *cm
+			cp.User = r.s.userOf(cm.UserID)
+			comments = append(comments, cp)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+	return comments, nil
+}
+
+func (r *memoryCommentRepository) FindByIDs(_ context.Context, ids []uint) ([]Comment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	comments := []Comment{}
+	for _, id := range ids {
+		cm, ok := r.s.comments[id]
+		if !ok || deleted(&cm.Model) {
+			continue
+		}
+		p, ok := r.s.posts[cm.PostID]
+		if !ok || deleted(&p.Model) {
+			continue
+		}
+		cp := *cm
+		cp.User = r.s.userOf(cm.UserID)
+		cp.Post = *p
+		comments = append(comments, cp)
+	}
+	return comments, nil
+}
+
+func (r *memoryCommentRepository) Update(_ context.Context, comment *Comment, content string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	cm, ok := r.s.comments[comment.ID]
+	if !ok || deleted(&cm.Model) {
+		return ErrNotFound
+	}
+	cm.Content = content
+	cm.UpdatedAt = time.Now()
+	comment.Content, comment.UpdatedAt = cm.Content, cm.UpdatedAt
+	return nil
+}
+
+func (r *memoryCommentRepository) Delete(_ context.Context, comment *Comment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if cm, ok := r.s.comments[comment.ID]; ok {
+		cm.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+// ---------------------- 令牌 ----------------------
+
+type memoryTokenRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryTokenRepository) CreateRefreshToken(_ context.Context, token *RefreshToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.refreshTokens {
+		if t.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+	token.ID = r.s.newID("refresh_tokens")
+	token.CreatedAt = time.Now()
+	cp := *token
+	r.s.refreshTokens[cp.ID] = &cp
+	return nil
+}
+
+func (r *memoryTokenRepository) findRefreshToken(match func(*RefreshToken) bool) (*RefreshToken, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, t := range r.s.refreshTokens {
+		if match(t) {
+			cp := *t
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryTokenRepository) FindRefreshTokenByHash(_ context.Context, hash string) (*RefreshToken, error) {
+	return r.findRefreshToken(func(t *RefreshToken) bool { return t.TokenHash == hash })
+}
+
+func (r *memoryTokenRepository) FindRefreshTokenByAccessJTI(_ context.Context, jti string) (*RefreshToken, error) {
+	return r.findRefreshToken(func(t *RefreshToken) bool { return t.AccessJTI == jti })
+}
+
+func (r *memoryTokenRepository) MarkRefreshTokenUsed(_ context.Context, id uint, at time.Time) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	t, ok := r.s.refreshTokens[id]
+	if !ok || t.UsedAt != nil || t.RevokedAt != nil {
+		return false, nil
+	}
+	t.UsedAt = &at
+	return true, nil
+}
+
+func (r *memoryTokenRepository) RevokeFamily(_ context.Context, familyID string, accessTTL time.Duration) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	now := time.Now()
+	for _, t := range r.s.refreshTokens {
+		if t.FamilyID != familyID {
+			continue
+		}
+		if exp := t.CreatedAt.Add(accessTTL); t.AccessJTI != "" && exp.After(now) {
+			r.s.revoked[t.AccessJTI] = &RevokedToken{JTI: t.AccessJTI, ExpiresAt: exp, CreatedAt: now}
+		}
+		if t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) RevokeUserTokens(_ context.Context, userID uint, accessTTL time.Duration) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	now := time.Now()
+	for _, t := range r.s.refreshTokens {
+		if t.UserID != userID {
+			continue
+		}
+		if exp := t.CreatedAt.Add(accessTTL); t.AccessJTI != "" && exp.After(now) {
+			r.s.revoked[t.AccessJTI] = &RevokedToken{JTI: t.AccessJTI, ExpiresAt: exp, CreatedAt: now}
+		}
+		if t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) RevokeAccessToken(_ context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" || expiresAt.Before(time.Now()) {
+		return nil
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.revoked[jti]; !ok {
+		r.s.revoked[jti] = &RevokedToken{JTI: jti, ExpiresAt: expiresAt, CreatedAt: time.Now()}
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) IsAccessTokenRevoked(_ context.Context, jti string) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	_, ok := r.s.revoked[jti]
+	return ok, nil
+}
+
+func (r *memoryTokenRepository) PruneRevokedTokens(_ context.Context, before time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for jti, t := range r.s.revoked {
+		if t.ExpiresAt.Before(before) {
+			delete(r.s.revoked, jti)
+		}
+	}
+	return nil
+}
+
+// ---------------------- 审计日志 ----------------------
+
+type memoryAuditRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryAuditRepository) Create(_ context.Context, entry *AuditLog) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	entry.ID = r.s.newID("audit_logs")
+	entry.CreatedAt = time.Now()
+	r.s.audits = append(r.s.audits, *entry)
+	return nil
+}
+
+func (r *memoryAuditRepository) List(_ context.Context, q *AuditQuery) ([]AuditLog, int64, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	all := []AuditLog{}
+	for i := len(r.s.audits) - 1; i >= 0; i-- { // 倒序
+		if q.ActorID == 0 || r.s.audits[i].ActorID == q.ActorID {
+			all = append(all, r.s.audits[i])
+		}
+	}
+	total := int64(len(all))
+	start := (q.Page - 1) * q.PageSize
+	if start > len(all) {
+		start = len(all)
+	}
+	end := start + q.PageSize
+	if end > len(all) {
+		end = len(all)
+	}
+	return all[start:end], total, nil
+}
+
+// ---------------------- 标签和分类 ----------------------
+
+type memoryTagRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryTagRepository) FindOrCreateTags(_ context.Context, names []string) ([]Tag, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	byName := make(map[string]*Tag, len(r.s.tags))
+	for _, t := range r.s.tags {
+		byName[t.Name] = t
+	}
+	tags := []Tag{}
+	for _, name := range names {
+		t, ok := byName[name]
+		if !ok {
+			t = &Tag{ID: r.s.newID("tags"), Name: name, CreatedAt: time.Now()}
+			r.s.tags[t.ID] = t
+			byName[name] = t
+		}
+		tags = append(tags, *t)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (r *memoryTagRepository) FindOrCreateCategory(_ context.Context, name string) (*Category, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, c := range r.s.categories {
+		if c.Name == name {
+			cp := *c
+			return &cp, nil
+		}
+	}
+	c := &Category{ID: r.s.newID("categories"), Name: name, CreatedAt: time.Now()}
+	r.s.categories[c.ID] = c
+	cp := *c
+	return &cp, nil
+}
+
+// livePostCounts 统计每个key下已发布的文章数，调用方需持有锁
+func (s *memoryStore) livePostCounts(keys func(p *Post) []uint) map[uint]int64 {
+	counts := make(map[uint]int64)
+	for _, p := range s.posts {
+		if deleted(&p.Model) || p.Status != StatusPublished {
+			continue
+		}
+		for _, k := range keys(p) {
+			counts[k]++
+		}
+	}
+	return counts
+}
+
+func (r *memoryTagRepository) ListTags(_ context.Context) ([]TagCount, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	counts := r.s.livePostCounts(func(p *Post) []uint { return r.s.postTags[p.ID] })
+	tags := []TagCount{}
+	for id, n := range counts {
+		if t, ok := r.s.tags[id]; ok {
+			tags = append(tags, TagCount{ID: id, Name: t.Name, PostCount: n})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].PostCount != tags[j].PostCount {
+			return tags[i].PostCount > tags[j].PostCount
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+func (r *memoryTagRepository) ListCategories(_ context.Context) ([]CategoryCount, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	counts := r.s.livePostCounts(func(p *Post) []uint {
+		if p.CategoryID == nil {
+			return nil
+		}
+		return []uint{*p.CategoryID}
+	})
+	categories := []CategoryCount{}
+	for id, n := range counts {
+		if c, ok := r.s.categories[id]; ok {
+			categories = append(categories, CategoryCount{ID: id, Name: c.Name, PostCount: n})
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].PostCount != categories[j].PostCount {
+			return categories[i].PostCount > categories[j].PostCount
+		}
+		return categories[i].Name < categories[j].Name
+	})
+	return categories, nil
+}
+
+// ---------------------- 文章修订历史 ----------------------
+
+type memoryRevisionRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryRevisionRepository) Create(_ context.Context, rev *PostRevision) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	rev.ID = r.s.newID("post_revisions")
+	rev.Rev = len(r.s.revisions[rev.PostID]) + 1
+	if rev.CreatedAt.IsZero() {
+		rev.CreatedAt = time.Now()
+	}
+	cp := *rev
+	cp.Editor = User{}
+	r.s.revisions[rev.PostID] = append(r.s.revisions[rev.PostID], cp)
+	return nil
+}
+
+// load 返回带修改人的版本副本，调用方需持有锁
+func (r *memoryRevisionRepository) load(rev PostRevision) *PostRevision {
+	rev.Editor = r.s.userOf(rev.EditorID)
+	return &rev
+}
+
+func (r *memoryRevisionRepository) Latest(_ context.Context, postID uint) (*PostRevision, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	revs := r.s.revisions[postID]
+	if len(revs) == 0 {
+		return nil, ErrNotFound
+	}
+	return r.load(revs[len(revs)-1]), nil
+}
+
+func (r *memoryRevisionRepository) Find(_ context.Context, postID uint, rev int) (*PostRevision, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	revs := r.s.revisions[postID]
+	if rev < 1 || rev > len(revs) {
+		return nil, ErrNotFound
+	}
+	return r.load(revs[rev-1]), nil
+}
+
+func (r *memoryRevisionRepository) ListByPost(_ context.Context, postID uint) ([]PostRevision, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	revs := r.s.revisions[postID]
+	out := make([]PostRevision, 0, len(revs))
+	for i := len(revs) - 1; i >= 0; i-- {
+		out = append(out, *r.load(revs[i]))
+	}
+	return out, nil
+}
+
+// ---------------------- 附件 ----------------------
+
+type memoryAttachmentRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryAttachmentRepository) Create(_ context.Context, a *Attachment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, x := range r.s.attachments {
+		if x.UserID == a.UserID && x.Hash == a.Hash {
+			return ErrDuplicate
+		}
+	}
+	a.ID = r.s.newID("attachments")
+	a.CreatedAt = time.Now()
+	cp := *a
+	r.s.attachments[a.ID] = &cp
+	return nil
+}
+
+func (r *memoryAttachmentRepository) FindByID(_ context.Context, id uint) (*Attachment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	a, ok := r.s.attachments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *a
+	return &cp, nil
+}
+
+func (r *memoryAttachmentRepository) FindByUserHash(_ context.Context, userID uint, hash string) (*Attachment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, a := range r.s.attachments {
+		if a.UserID == userID && a.Hash == hash {
+			cp := *a
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryAttachmentRepository) HashInUse(_ context.Context, hash string) (bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, a := range r.s.attachments {
+		if a.Hash == hash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryAttachmentRepository) SetPost(_ context.Context, a *Attachment, postID *uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	stored, ok := r.s.attachments[a.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.PostID = postID
+	a.PostID = postID
+	return nil
+}
+
+func (r *memoryAttachmentRepository) ListByPost(_ context.Context, postID uint) ([]Attachment, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	out := []Attachment{}
+	for _, a := range r.s.attachments {
+		if a.PostID != nil && *a.PostID == postID {
+			out = append(out, *a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (r *memoryAttachmentRepository) Delete(_ context.Context, a *Attachment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.attachments[a.ID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.attachments, a.ID)
+	return nil
+}
+
+// ---------------------- 表态 ----------------------
+
+type memoryReactionRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryReactionRepository) Toggle(_ context.Context, reaction *Reaction) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for id, x := range r.s.reactions {
+		if x.UserID == reaction.UserID && x.TargetType == reaction.TargetType &&
+			x.TargetID == reaction.TargetID && x.Kind == reaction.Kind {
+			delete(r.s.reactions, id)
+			return false, nil
+		}
+	}
+	reaction.ID = r.s.newID("reactions")
+	reaction.CreatedAt = time.Now()
+	cp := *reaction
+	r.s.reactions[cp.ID] = &cp
+	return true, nil
+}
+
+func (r *memoryReactionRepository) Summaries(_ context.Context, targetType string, ids []uint, viewerID uint) (map[uint][]ReactionCount, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	wanted := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var tallies []reactionTally
+	mine := make(map[uint]map[string]bool)
+	for _, x := range r.s.reactions {
+		if x.TargetType != targetType || !wanted[x.TargetID] {
+			continue
+		}
+		tallies = append(tallies, reactionTally{TargetID: x.TargetID, Kind: x.Kind, Count: 1})
+		if viewerID != 0 && x.UserID == viewerID {
+			if mine[x.TargetID] == nil {
+				mine[x.TargetID] = make(map[string]bool)
+			}
+			mine[x.TargetID][x.Kind] = true
+		}
+	}
+	return groupReactions(tallies, mine), nil
+}
+
+// ---------------------- 关注 ----------------------
+
+type memoryFollowRepository struct {
+	s *memoryStore
+}
+
+// findFollow 查找关注关系，调用方需持有锁
+func (s *memoryStore) findFollow(followerID, followeeID uint) *Follow {
+	for _, f := range s.follows {
+		if f.FollowerID == followerID && f.FolloweeID == followeeID {
+			return f
+		}
+	}
+	return nil
+}
+
+func (r *memoryFollowRepository) Follow(_ context.Context, followerID, followeeID uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if r.s.findFollow(followerID, followeeID) != nil {
+		return false, nil
+	}
+	f := &Follow{ID: r.s.newID("follows"), FollowerID: followerID, FolloweeID: followeeID, CreatedAt: time.Now()}
+	r.s.follows[f.ID] = f
+	return true, nil
+}
+
+func (r *memoryFollowRepository) Unfollow(_ context.Context, followerID, followeeID uint) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	f := r.s.findFollow(followerID, followeeID)
+	if f == nil {
+		return false, nil
+	}
+	delete(r.s.follows, f.ID)
+	return true, nil
+}
+
+func (r *memoryFollowRepository) ListFollowers(_ context.Context, userID uint, q *FollowQuery) ([]FollowView, int64, error) {
+	return r.list(q, func(f *Follow) (bool, uint) { return f.FolloweeID == userID, f.FollowerID })
+}
+
+func (r *memoryFollowRepository) ListFollowing(_ context.Context, userID uint, q *FollowQuery) ([]FollowView, int64, error) {
+	return r.list(q, func(f *Follow) (bool, uint) { return f.FollowerID == userID, f.FolloweeID })
+}
+
+// list match返回关注关系是否符合条件，以及要列出的那一边的用户ID
+func (r *memoryFollowRepository) list(q *FollowQuery, match func(*Follow) (bool, uint)) ([]FollowView, int64, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	var follows []*Follow
+	for _, f := range r.s.follows {
+		if ok, id := match(f); ok && r.s.userOf(id).ID != 0 {
+			follows = append(follows, f)
+		}
+	}
+	sort.Slice(follows, func(i, j int) bool { return follows[i].ID > follows[j].ID })
+
+	users := []FollowView{}
+	for i := (q.Page - 1) * q.PageSize; i < len(follows) && len(users) < q.PageSize; i++ {
+		_, id := match(follows[i])
+		users = append(users, FollowView{ID: id, Username: r.s.userOf(id).Username, FollowedAt: follows[i].CreatedAt})
+	}
+	return users, int64(len(follows)), nil
+}
+
+// ---------------------- 通知 ----------------------
+
+type memoryNotificationRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryNotificationRepository) Create(_ context.Context, notifications []Notification) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i := range notifications {
+		n := &notifications[i]
+		n.ID = r.s.newID("notifications")
+		n.CreatedAt = time.Now()
+		cp := *n
+		cp.Actor = User{}
+		r.s.notifications[cp.ID] = &cp
+	}
+	return nil
+}
+
+func (r *memoryNotificationRepository) List(_ context.Context, userID uint, q *NotificationQuery) ([]Notification, int64, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	all := []Notification{}
+	for _, n := range r.s.notifications {
+		if n.UserID == userID && (!q.Unread || n.ReadAt == nil) {
+			cp := *n
+			cp.Actor = r.s.userOf(n.ActorID)
+			all = append(all, cp)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID > all[j].ID })
+	start := (q.Page - 1) * q.PageSize
+	if start > len(all) {
+		start = len(all)
+	}
+	end := start + q.PageSize
+	if end > len(all) {
+		end = len(all)
+	}
+	return all[start:end], int64(len(all)), nil
+}
+
+func (r *memoryNotificationRepository) UnreadCounts(_ context.Context, userID uint) (map[string]int64, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	counts := make(map[string]int64)
+	for _, n := range r.s.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			counts[n.Type]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *memoryNotificationRepository) MarkRead(_ context.Context, userID, id uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	n, ok := r.s.notifications[id]
+	if !ok || n.UserID != userID {
+		return ErrNotFound
+	}
+	if n.ReadAt == nil {
+		n.ReadAt = &at
+	}
+	return nil
+}
+
+func (r *memoryNotificationRepository) MarkAllRead(_ context.Context, userID uint, at time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	var marked int64
+	for _, n := range r.s.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			n.ReadAt = &at
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (r *memoryNotificationRepository) MutedTypes(_ context.Context, userID uint) (map[string]bool, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	muted := make(map[string]bool)
+	for typ, m := range r.s.mutedTypes[userID] {
+		if m {
+			muted[typ] = true
+		}
+	}
+	return muted, nil
+}
+
+func (r *memoryNotificationRepository) SetMuted(_ context.Context, userID uint, muted map[string]bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	prefs := make(map[string]bool, len(NotificationTypes))
+	for _, typ := range NotificationTypes {
+		prefs[typ] = muted[typ]
+	}
+	r.s.mutedTypes[userID] = prefs
+	return nil
+}
+
+// ---------------------- 邮箱验证和重置密码令牌 ----------------------
+
+type memoryAccountTokenRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryAccountTokenRepository) Create(_ context.Context, token *AccountToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.accountTokens {
+		if t.TokenHash == token.TokenHash {
+			return &DuplicateError{Table: "account_tokens", Field: "token_hash"}
+		}
+	}
+	token.ID = r.s.newID("account_tokens")
+	token.CreatedAt = time.Now()
+	cp := *token
+	r.s.accountTokens[cp.ID] = &cp
+	return nil
+}
+
+func (r *memoryAccountTokenRepository) Consume(_ context.Context, hash, purpose string, at time.Time) (*AccountToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.accountTokens {
+		if t.TokenHash != hash {
+			continue
+		}
+		if t.Purpose != purpose || t.UsedAt != nil || !t.ExpiresAt.After(at) {
+			return nil, ErrNotFound
+		}
+		t.UsedAt = &at
+		cp := *t
+		return &cp, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryAccountTokenRepository) Invalidate(_ context.Context, userID uint, purpose string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.accountTokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &at
+		}
+	}
+	return nil
+}
+
+// ---------------------- 个人访问令牌 ----------------------
+
+type memoryPersonalTokenRepository struct {
+	s *memoryStore
+}
+
+func (r *memoryPersonalTokenRepository) Create(_ context.Context, token *PersonalAccessToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.personalTokens {
+		if t.TokenHash == token.TokenHash {
+			return &DuplicateError{Table: "personal_access_tokens", Field: "token_hash"}
+		}
+	}
+	token.ID = r.s.newID("personal_access_tokens")
+	token.CreatedAt = time.Now()
+	cp := *token
+	r.s.personalTokens[cp.ID] = &cp
+	return nil
+}
+
+func (r *memoryPersonalTokenRepository) FindByHash(_ context.Context, hash string) (*PersonalAccessToken, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	for _, t := range r.s.personalTokens {
+		if t.TokenHash == hash {
+			cp := *t
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryPersonalTokenRepository) ListByUser(_ context.Context, userID uint) ([]PersonalAccessToken, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+	tokens := []PersonalAccessToken{}
+	for _, t := range r.s.personalTokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			tokens = append(tokens, *t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+	return tokens, nil
+}
+
+func (r *memoryPersonalTokenRepository) Revoke(_ context.Context, userID, id uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	t, ok := r.s.personalTokens[id]
+	if !ok || t.UserID != userID || t.RevokedAt != nil {
+		return ErrNotFound
+	}
+	t.RevokedAt = &at
+	return nil
+}
+
+func (r *memoryPersonalTokenRepository) RevokeAll(_ context.Context, userID uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, t := range r.s.personalTokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memoryPersonalTokenRepository) Touch(_ context.Context, id uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if t, ok := r.s.personalTokens[id]; ok {
+		t.LastUsedAt = &at
+	}
+	return nil
+}
+
+// ---------------------- 健康检查 ----------------------
+
+// memoryHealthChecker 内存存储总是就绪的
+type memoryHealthChecker struct{}
+
+func (memoryHealthChecker) Ready(context.Context) error {
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// ====================== 文章修订历史：版本列表 + 对比 + 回滚 ======================
+// 文章标题或内容每变化一次就保存一个完整快照，版本号从1开始按文章递增
+// 回滚不会删除历史，而是把旧版本的内容作为一个新版本保存
+
+// PostRevision 文章修订表
+type PostRevision struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_post_rev" json:"post_id"`
+	Rev       int       `gorm:"not null;uniqueIndex:idx_post_rev" json:"rev"` // 版本号，同一篇文章内从1递增
+	Title     string    `gorm:"not null;type:varchar(100)" json:"title"`
+	Content   string    `gorm:"not null;type:text" json:"content,omitempty"` // 列表接口不返回
+	EditorID  uint      `gorm:"not null" json:"editor_id"`                   // 这一版的修改人
+	Editor    User      `gorm:"foreignKey:EditorID" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RevisionView 修订接口返回的版本信息，修改人只返回ID和用户名
+type RevisionView struct {
+	PostRevision
+	EditorName string `json:"editor_name"`
+}
+
+func revisionView(rev PostRevision) RevisionView {
+	return RevisionView{PostRevision: rev, EditorName: rev.Editor.Username}
+}
+
+// recordRevision 文章创建或修改后保存一个版本；标题和内容都没变时不保存
+// before是修改前的文章，为nil表示新建；老文章第一次修改时先把修改前的内容补存为第1版
+func (s *Server) recordRevision(ctx context.Context, before, after *Post, editorID uint) error {
+	if before != nil {
+		if before.Title == after.Title && before.Content == after.Content {
+			return nil
+		}
+		_, err := s.Revisions.Latest(ctx, after.ID)
+		if errors.Is(err, ErrNotFound) {
+			baseline := PostRevision{PostID: before.ID, Title: before.Title, Content: before.Content,
+				EditorID: before.UserID, CreatedAt: before.UpdatedAt}
+			err = s.Revisions.Create(ctx, &baseline)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return s.Revisions.Create(ctx, &PostRevision{PostID: after.ID, Title: after.Title, Content: after.Content, EditorID: editorID})
+}
+
+// revisionPost 修订接口的公共逻辑：解析文章ID并检查当前用户能否看到这篇文章，失败时已调用fail
+func (s *Server) revisionPost(c *gin.Context) (*Post, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, errInvalidPostID)
+		return nil, false
+	}
+	post, err := s.Posts.FindByID(c.Request.Context(), uint(id))
+	if err != nil || !canViewPost(c, post) {
+		fail(c, errPostNotFound)
+		return nil, false
+	}
+	return post, true
+}
+
+// findRevision 按版本号查找修订，失败时已调用fail
+func (s *Server) findRevision(c *gin.Context, postID uint, revStr string) (*PostRevision, bool) {
+	rev, err := strconv.Atoi(revStr)
+	if err != nil || rev <= 0 {
+		fail(c, errInvalidRevID)
+		return nil, false
+	}
+	r, err := s.Revisions.Find(c.Request.Context(), postID, rev)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			fail(c, errRevNotFound.WithMessage("版本 "+revStr+" 不存在"))
+		} else {
+			log.Errorf("查询文章版本失败: %v", err)
+			fail(c, apperr.Internal("查询版本失败"))
+		}
+		return nil, false
+	}
+	return r, true
+}
+
+// ListRevisions 获取文章的修订历史 GET /api/posts/:id/revisions 【无需登录，未发布文章只有作者可看】
+// 按版本号倒序，不包含正文
+func (s *Server) ListRevisions(c *gin.Context) {
+	post, ok := s.revisionPost(c)
+	if !ok {
+		return
+	}
+	revs, err := s.Revisions.ListByPost(c.Request.Context(), post.ID)
+	if err != nil {
+		log.Errorf("获取修订历史失败: %v", err)
+		fail(c, apperr.Internal("获取修订历史失败"))
+		return
+	}
+	views := make([]RevisionView, len(revs))
+	for i, r := range revs {
+		r.Content = ""
+		views[i] = revisionView(r)
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "获取成功", "data": views})
+}
+
+// GetRevision 获取某个版本的完整内容 GET /api/posts/:id/revisions/:rev 【无需登录，未发布文章只有作者可看】
+func (s *Server) GetRevision(c *gin.Context) {
+	post, ok := s.revisionPost(c)
+	if !ok {
+		return
+	}
+	rev, ok := s.findRevision(c, post.ID, c.Param("rev"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "获取成功", "data": revisionView(*rev)})
+}
+
+// RevisionDiff 两个版本的对比结果
+type RevisionDiff struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Diff string `json:"diff"` // unified diff，两个版本相同时为空字符串
+}
+
+// DiffRevisions 对比两个版本 GET /api/posts/:id/revisions/diff?from=1&to=2 【无需登录，未发布文章只有作者可看】
+// 返回unified diff；format=raw 时直接返回diff文本，可以配合patch等工具使用
+func (s *Server) DiffRevisions(c *gin.Context) {
+	post, ok := s.revisionPost(c)
+	if !ok {
+		return
+	}
+	from, ok := s.findRevision(c, post.ID, c.Query("from"))
+	if !ok {
+		return
+	}
+	to, ok := s.findRevision(c, post.ID, c.Query("to"))
+	if !ok {
+		return
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(revisionText(from)),
+		B:        difflib.SplitLines(revisionText(to)),
+		FromFile: "rev" + strconv.Itoa(from.Rev),
+		ToFile:   "rev" + strconv.Itoa(to.Rev),
+		FromDate: from.CreatedAt.Format(time.RFC3339),
+		ToDate:   to.CreatedAt.Format(time.RFC3339),
+		Context:  3,
+	})
+	if err != nil {
+		log.Errorf("生成diff失败: %v", err)
+		fail(c, apperr.Internal("生成diff失败"))
+		return
+	}
+
+	if c.Query("format") == "raw" {
+		c.String(http.StatusOK, diff)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "获取成功", "data": RevisionDiff{From: from.Rev, To: to.Rev, Diff: diff}})
+}
+
+// revisionText 把一个版本转换成用于对比的文本：第一行是标题，空一行后是正文
+func revisionText(r *PostRevision) string {
+	text := "标题: " + r.Title + "\n\n" + r.Content
+	if len(text) > 0 && text[len(text)-1] != '\n' {
+		text += "\n"
+	}
+	return text
+}
+
+// RestoreRevision 回滚到某个版本 POST /api/posts/:id/revisions/:rev/restore 【需要登录+只有作者或版主可操作】
+// 文章的标题和内容恢复成该版本，并保存为一个新版本
+func (s *Server) RestoreRevision(c *gin.Context) {
+	post, ok := s.revisionPost(c)
+	if !ok {
+		return
+	}
+	ok, privileged := authorize(c, post.UserID, PermEditAnyPost)
+	if !ok {
+		fail(c, errPostForbidden)
+		return
+	}
+	rev, ok := s.findRevision(c, post.ID, c.Param("rev"))
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	before := *post
+	if err := s.Posts.Update(ctx, post, Post{Title: rev.Title, Content: rev.Content}); err != nil {
+		log.Errorf("回滚文章失败: %v", err)
+		fail(c, apperr.Internal("回滚文章失败"))
+		return
+	}
+	if err := s.recordRevision(ctx, &before, post, c.GetUint("userID")); err != nil {
+		log.Errorf("保存文章版本失败: %v", err)
+		fail(c, apperr.Internal("回滚文章失败"))
+		return
+	}
+	if privileged {
+		s.audit(c, AuditPostUpdate, "post", post.ID, "回滚到版本"+strconv.Itoa(rev.Rev))
+	}
+	log.Infof("用户ID:%d 把文章ID:%d 回滚到版本%d", c.GetUint("userID"), post.ID, rev.Rev)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "回滚成功！", "data": s.viewPost(c, post)})
+}
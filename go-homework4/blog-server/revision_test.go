@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRevisionUpdateAndRestore(t *testing.T) {
+	_, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	id := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "标题一", "content": "第一行\n第二行"}, 200), "id")
+	base := fmt.Sprint("/api/posts/", id, "/revisions")
+
+	sc.call("PUT", fmt.Sprint("/api/posts/", id), alice, gin.H{"title": "标题二", "content": "第一行\n改过的第二行"}, 200)
+	// 只改标签不产生新版本
+	sc.call("PUT", fmt.Sprint("/api/posts/", id), alice, gin.H{"tags": []string{"go"}}, 200)
+	restored := sc.call("POST", base+"/1/restore", alice, nil, 200)
+	if data := restored["data"].(map[string]interface{}); data["title"] != "标题一" || data["content"] != "第一行\n第二行" {
+		t.Errorf("回滚后文章是 %v", data)
+	}
+
+	// 创建、修改、回滚各一个版本，回滚的版本内容和第1版一样
+	want := []struct{ title, content string }{
+		{"标题一", "第一行\n第二行"},
+		{"标题二", "第一行\n改过的第二行"},
+		{"标题一", "第一行\n第二行"},
+	}
+	list := sc.call("GET", base, "", nil, 200)["data"].([]interface{})
+	if len(list) != len(want) {
+		t.Fatalf("有 %d 个版本，期望 %d 个: %v", len(list), len(want), list)
+	}
+	for i, w := range want {
+		rev := sc.call("GET", fmt.Sprint(base, "/", i+1), "", nil, 200)["data"].(map[string]interface{})
+		if rev["rev"] != float64(i+1) || rev["title"] != w.title || rev["content"] != w.content {
+			t.Errorf("版本 %d 是 %v，期望 %+v", i+1, rev, w)
+		}
+	}
+
+	// 前两行是带时间的文件头，只检查文件名
+	diff := sc.call("GET", base+"/diff?from=1&to=2", "", nil, 200)["data"].(map[string]interface{})["diff"]
+	raw := sc.send(httptest.NewRequest("GET", base+"/diff?from=1&to=2&format=raw", nil), "", 200).Body.String()
+	lines := strings.SplitN(raw, "\n", 3)
+	if raw != diff || len(lines) != 3 || !strings.HasPrefix(lines[0], "--- rev1\t") || !strings.HasPrefix(lines[1], "+++ rev2\t") {
+		t.Fatalf("diff 不对:\n%v\nraw:\n%s", diff, raw)
+	}
+	if want := "@@ -1,5 +1,5 @@\n-标题: 标题一\n+标题: 标题二\n \n 第一行\n-第二行\n+改过的第二行\n \n"; lines[2] != want {
+		t.Errorf("diff 内容是\n%s期望\n%s", lines[2], want)
+	}
+	if d := sc.call("GET", base+"/diff?from=1&to=3", "", nil, 200)["data"].(map[string]interface{})["diff"]; d != "" {
+		t.Errorf("内容相同的版本 diff 应该为空，得到 %q", d)
+	}
+}
+
+// failingRevisions 保存版本总是失败的仓储
+type failingRevisions struct {
+	RevisionRepository
+}
+
+func (failingRevisions) Create(context.Context, *PostRevision) error {
+	return errors.New("数据库不可用")
+}
+
+func TestRevisionFailureReturnsError(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	id := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "标题", "content": "内容"}, 200), "id")
+	s.Revisions = failingRevisions{s.Revisions}
+
+	// 版本保存失败时不能当作成功返回
+	sc.call("POST", "/api/posts", alice, gin.H{"title": "标题", "content": "内容"}, 500)
+	sc.call("PUT", fmt.Sprint("/api/posts/", id), alice, gin.H{"content": "新内容"}, 500)
+	sc.call("POST", fmt.Sprint("/api/posts/", id, "/revisions/1/restore"), alice, nil, 500)
+}
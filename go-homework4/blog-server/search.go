@@ -0,0 +1,618 @@
+package main
+
+import (
+	"context"
+	"html"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ====================== 全文搜索：文章标题、文章内容、评论内容 ======================
+// PostgreSQL：使用 tsvector 表达式索引(GIN) + ts_rank 排序 + ts_headline 高亮
+// SQLite：进程内倒排索引，启动时从数据库全量构建，之后通过GORM回调增量维护
+
+// SearchQuery GET /api/search 的查询参数
+type SearchQuery struct {
+	Q        string `form:"q" binding:"required"` // 搜索关键字，多个词用空格分隔，需全部命中
+	Type     string `form:"type"`                 // 搜索范围：all(默认) / post / comment
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+// SearchResult 一条搜索结果
+type SearchResult struct {
+	Type      string    `json:"type"`    // post / comment
+	ID        uint      `json:"id"`      // 文章ID或评论ID
+	PostID    uint      `json:"post_id"` // 所属文章ID，文章结果等于ID
+	Title     string    `json:"title"`   // 文章标题，命中部分带<mark>高亮
+	Snippet   string    `json:"snippet"` // 正文片段，命中部分带<mark>高亮
+	Score     float64   `json:"score"`   // 相关度，越大越靠前
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Searcher 全文搜索的实现，按数据库驱动选择
+type Searcher interface {
+	Search(ctx context.Context, q *SearchQuery) (results []SearchResult, total int64, err error)
+}
+
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+	snippetRunes   = 120 // 内存索引模式下片段的最大长度（字符数）
+)
+
+// NewSearcher 在表迁移完成后调用，按数据库驱动创建搜索实现
+func NewSearcher(db *gorm.DB, driver string, repos Repositories) (Searcher, error) {
+	if driver == "postgres" {
+		// 表达式索引由迁移 0002_search_indexes 创建，索引表达式必须与查询中的完全一致才能被查询规划器用上
+		return &pgSearcher{db}, nil
+	}
+
+	idx := newInvertedIndex()
+	var posts []Post
+	if err := db.Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	for i := range posts {
+		idx.indexPost(&posts[i])
+	}
+	var comments []Comment
+	if err := db.Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	for i := range comments {
+		idx.indexComment(&comments[i])
+	}
+	if err := idx.registerCallbacks(db); err != nil {
+		return nil, err
+	}
+	log.Infof("内存搜索索引构建完成：%d 篇文章，%d 条评论", len(posts), len(comments))
+	return &memorySearcher{idx: idx, posts: repos.Posts, comments: repos.Comments}, nil
+}
+
+// Search 全文搜索 GET /api/search 【无需登录】
+func (s *Server) Search(c *gin.Context) {
+	var q SearchQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	q.Q = strings.TrimSpace(q.Q)
+	switch q.Type {
+	case "":
+		q.Type = "all"
+	case "all", "post", "comment":
+	default:
+		fail(c, errInvalidSearchType)
+		return
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+
+	results, total, err := s.search.Search(c.Request.Context(), &q)
+	if err != nil {
+		log.Errorf("搜索失败: %v", err)
+		fail(c, apperr.Internal("搜索失败"))
+		return
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "搜索成功",
+		"data": results,
+		"pagination": Pagination{
+			Page:     q.Page,
+			PageSize: q.PageSize,
+			Total:    total,
+		},
+	})
+}
+
+// ---------------------- PostgreSQL 实现 ----------------------
+
+// pgPostVector 文章的tsvector表达式：标题权重A，内容权重B；prefix为表名前缀
+// 修改表达式时要加一个迁移重建 idx_posts_fts / idx_comments_fts 索引
+func pgPostVector(prefix string) string {
+	return "setweight(to_tsvector('simple', coalesce(" + prefix + "title, '')), 'A') || " +
+		"setweight(to_tsvector('simple', coalesce(" + prefix + "content, '')), 'B')"
+}
+
+func pgCommentVector(prefix string) string {
+	return "to_tsvector('simple', coalesce(" + prefix + "content, ''))"
+}
+
+// ts_headline 不转义原文，先用私用区字符标记命中位置，查出来后整体做HTML转义，再把标记换成<mark>，见 pgHighlight
+const (
+	pgMarkStart = "\uE000"
+	pgMarkStop  = "\uE001"
+)
+
+const pgHeadlineOpts = "'StartSel=" + pgMarkStart + ", StopSel=" + pgMarkStop + ", MaxWords=35, MinWords=15, MaxFragments=2'"
+
+var pgMarkReplacer = strings.NewReplacer(pgMarkStart, highlightStart, pgMarkStop, highlightStop)
+
+// pgHighlight 把ts_headline的结果转成和内存索引一样的格式：HTML转义，只有<mark>标签是原样的
+func pgHighlight(s string) string {
+	return pgMarkReplacer.Replace(html.EscapeString(s))
+}
+
+// pgSearcher PostgreSQL全文搜索
+type pgSearcher struct {
+	db *gorm.DB
+}
+
+func (ps *pgSearcher) Search(ctx context.Context, q *SearchQuery) ([]SearchResult, int64, error) {
+	var parts []string
+	var args []interface{}
+	if q.Type != "comment" {
+		parts = append(parts, `SELECT 'post' AS type, posts.id AS id, posts.id AS post_id,
+	ts_headline('simple', posts.title, query, `+pgHeadlineOpts+`) AS title,
+	ts_headline('simple', posts.content, query, `+pgHeadlineOpts+`) AS snippet,
+	ts_rank(`+pgPostVector("posts.")+`, query) AS score, posts.created_at AS created_at
+FROM posts, plainto_tsquery('simple', ?) query
+WHERE posts.deleted_at IS NULL AND posts.status = 'published' AND `+pgPostVector("posts.")+` @@ query`)
+		args = append(args, q.Q)
+	}
+	if q.Type != "post" {
+		parts = append(parts, `SELECT 'comment' AS type, comments.id AS id, comments.post_id AS post_id,
+	posts.title AS title,
+	ts_headline('simple', comments.content, query, `+pgHeadlineOpts+`) AS snippet,
+	ts_rank(`+pgCommentVector("comments.")+`, query) AS score, comments.created_at AS created_at
+FROM comments JOIN posts ON posts.id = comments.post_id AND posts.deleted_at IS NULL AND posts.status = 'published',
+	plainto_tsquery('simple', ?) query
+WHERE comments.deleted_at IS NULL AND `+pgCommentVector("comments.")+` @@ query`)
+		args = append(args, q.Q)
+	}
+	union := strings.Join(parts, "\nUNION ALL\n")
+
+	tx := ps.db.WithContext(ctx)
+	var total int64
+	if err := tx.Raw("SELECT COUNT(*) FROM ("+union+") hits", args...).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var results []SearchResult
+	pageArgs := append(args, q.PageSize, (q.Page-1)*q.PageSize)
+	err := tx.Raw("SELECT * FROM ("+union+") hits ORDER BY score DESC, created_at DESC LIMIT ? OFFSET ?", pageArgs...).
+		Scan(&results).Error
+	for i := range results {
+		results[i].Title = pgHighlight(results[i].Title)
+		results[i].Snippet = pgHighlight(results[i].Snippet)
+	}
+	return results, total, err
+}
+
+// ---------------------- SQLite 内存倒排索引实现 ----------------------
+
+// docKey 索引中的文档标识
+type docKey struct {
+	Type string // post / comment
+	ID   uint
+}
+
+// invertedIndex 倒排索引：词 -> 文档 -> 加权词频
+type invertedIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[docKey]float64
+	docs     map[docKey][]string // 文档包含的词，删除/重建文档时用
+}
+
+const titleWeight = 3 // 标题中的词权重更高，对应PostgreSQL里的权重A
+
+func newInvertedIndex() *invertedIndex {
+	return &invertedIndex{
+		postings: make(map[string]map[docKey]float64),
+		docs:     make(map[docKey][]string),
+	}
+}
+
+func (idx *invertedIndex) indexPost(p *Post) {
+	tf := make(map[string]float64)
+	for _, t := range tokenize(p.Title) {
+		tf[t] += titleWeight
+	}
+	for _, t := range tokenize(p.Content) {
+		tf[t]++
+	}
+	idx.put(docKey{"post", p.ID}, tf)
+}
+
+func (idx *invertedIndex) indexComment(cm *Comment) {
+	tf := make(map[string]float64)
+	for _, t := range tokenize(cm.Content) {
+		tf[t]++
+	}
+	idx.put(docKey{"comment", cm.ID}, tf)
+}
+
+// put 写入（或覆盖）一个文档的索引
+func (idx *invertedIndex) put(key docKey, tf map[string]float64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(key)
+	terms := make([]string, 0, len(tf))
+	for t, n := range tf {
+		if idx.postings[t] == nil {
+			idx.postings[t] = make(map[docKey]float64)
+		}
+		idx.postings[t][key] = n
+		terms = append(terms, t)
+	}
+	idx.docs[key] = terms
+}
+
+func (idx *invertedIndex) remove(key docKey) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(key)
+}
+
+func (idx *invertedIndex) removeLocked(key docKey) {
+	for _, t := range idx.docs[key] {
+		delete(idx.postings[t], key)
+		if len(idx.postings[t]) == 0 {
+			delete(idx.postings, t)
+		}
+	}
+	delete(idx.docs, key)
+}
+
+// indexHit 内存检索命中的文档及得分
+type indexHit struct {
+	key   docKey
+	score float64
+}
+
+// search 所有查询词都必须命中(AND)，按 TF-IDF 累加得分；docType为空表示不限类型
+func (idx *invertedIndex) search(terms []string, docType string) []indexHit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if len(terms) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.docs))
+	scores := make(map[docKey]float64)
+	for i, t := range terms {
+		posting := idx.postings[t]
+		if len(posting) == 0 {
+			return nil
+		}
+		idf := math.Log(1 + n/float64(len(posting)))
+		next := make(map[docKey]float64)
+		for key, tf := range posting {
+			if docType != "" && key.Type != docType {
+				continue
+			}
+			if _, ok := scores[key]; i > 0 && !ok {
+				continue // 前面的词没命中这个文档
+			}
+			next[key] = scores[key] + (1+math.Log(tf))*idf
+		}
+		scores = next
+	}
+
+	hits := make([]indexHit, 0, len(scores))
+	for key, score := range scores {
+		hits = append(hits, indexHit{key, score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].key.ID > hits[j].key.ID // 同分时新内容优先
+	})
+	return hits
+}
+
+func isHan(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+// tokenize 分词：英文数字按连续字符切分并转小写；中文没有空格分隔，按单字+相邻双字切分
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var han []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		for i := range han {
+			tokens = append(tokens, string(han[i]))
+			if i+1 < len(han) {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isHan(r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+// uniqueTerms 查询词去重，保持顺序
+func uniqueTerms(tokens []string) []string {
+	seen := make(map[string]bool, len(tokens))
+	out := tokens[:0]
+	for _, t := range tokens {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// highlightWords 用于高亮的词：按空白和标点切开的原始词（中文不再拆字，避免满屏高亮）
+func highlightWords(q string) []string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	return uniqueTerms(words)
+}
+
+// highlight 在文本中标记命中的词并截取片段；maxRunes<=0表示不截取。输出已做HTML转义，只有<mark>标签是原样的
+func highlight(text string, words []string, maxRunes int) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		lower = runes // 极少数字符转小写后长度变化，退化为区分大小写匹配
+	}
+
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, w := range words {
+		wr := []rune(w)
+		if len(wr) == 0 {
+			continue
+		}
+		for i := 0; i+len(wr) <= len(lower); i++ {
+			if string(lower[i:i+len(wr)]) != w {
+				continue
+			}
+			for j := i; j < i+len(wr); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+
+	start, end := 0, len(runes)
+	if maxRunes > 0 && len(runes) > maxRunes {
+		if first > maxRunes/3 {
+			start = first - maxRunes/3 // 命中位置前保留一些上下文
+		}
+		end = start + maxRunes
+		if end > len(runes) {
+			end = len(runes)
+			start = end - maxRunes
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		seg := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			b.WriteString(highlightStart + seg + highlightStop)
+		} else {
+			b.WriteString(seg)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+// memorySearcher SQLite模式下基于内存倒排索引的搜索，命中的文档通过仓储加载
+type memorySearcher struct {
+	idx      *invertedIndex
+	posts    PostRepository
+	comments CommentRepository
+}
+
+func (ms *memorySearcher) Search(ctx context.Context, q *SearchQuery) ([]SearchResult, int64, error) {
+	docType := ""
+	if q.Type != "all" {
+		docType = q.Type
+	}
+	hits := ms.idx.search(uniqueTerms(tokenize(q.Q)), docType)
+
+	// 先加载全部命中文档：已软删除的文章、已删除文章下的评论在这里被过滤掉，未发布的文章和它的评论也不返回
+	var postIDs, commentIDs []uint
+	for _, h := range hits {
+		if h.key.Type == "post" {
+			postIDs = append(postIDs, h.key.ID)
+		} else {
+			commentIDs = append(commentIDs, h.key.ID)
+		}
+	}
+	posts := make(map[uint]Post)
+	rows, err := ms.posts.FindByIDs(ctx, postIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, p := range rows {
+		posts[p.ID] = p
+	}
+	comments := make(map[uint]Comment)
+	cms, err := ms.comments.FindByIDs(ctx, commentIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, cm := range cms {
+		comments[cm.ID] = cm
+	}
+
+	words := highlightWords(q.Q)
+	var all []SearchResult
+	for _, h := range hits {
+		switch h.key.Type {
+		case "post":
+			p, ok := posts[h.key.ID]
+			if !ok || p.Status != StatusPublished {
+				continue
+			}
+			all = append(all, SearchResult{
+				Type: "post", ID: p.ID, PostID: p.ID,
+				Title:     highlight(p.Title, words, 0),
+				Snippet:   highlight(p.Content, words, snippetRunes),
+				Score:     h.score,
+				CreatedAt: p.CreatedAt,
+			})
+		case "comment":
+			cm, ok := comments[h.key.ID]
+			if !ok || cm.Post.ID == 0 || cm.Post.Status != StatusPublished {
+				continue
+			}
+			all = append(all, SearchResult{
+				Type: "comment", ID: cm.ID, PostID: cm.PostID,
+				Title:     html.EscapeString(cm.Post.Title),
+				Snippet:   highlight(cm.Content, words, snippetRunes),
+				Score:     h.score,
+				CreatedAt: cm.CreatedAt,
+			})
+		}
+	}
+
+	total := int64(len(all))
+	from := (q.Page - 1) * q.PageSize
+	if from >= len(all) {
+		return nil, total, nil
+	}
+	to := from + q.PageSize
+	if to > len(all) {
+		to = len(all)
+	}
+	return all[from:to], total, nil
+}
+
+// ---------------------- GORM回调：增量维护内存索引 ----------------------
+
+// registerCallbacks 在数据库连接上注册回调：文章/评论创建、更新后重建索引，删除后移出索引
+func (idx *invertedIndex) registerCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("search:index_create", idx.afterSave); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("search:index_update", idx.afterSave); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("search:index_delete", idx.afterDelete)
+}
+
+// primaryKeys 取出本次操作涉及记录的主键，支持单条和批量
+func primaryKeys(tx *gorm.DB) []uint {
+	field := tx.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil
+	}
+	var ids []uint
+	collect := func(rv reflect.Value) {
+		rv = reflect.Indirect(rv)
+		if rv.Kind() != reflect.Struct {
+			return
+		}
+		if v, zero := field.ValueOf(tx.Statement.Context, rv); !zero {
+			if id, ok := v.(uint); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	switch rv := tx.Statement.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			collect(rv.Index(i))
+		}
+	default:
+		collect(rv)
+	}
+	return ids
+}
+
+// afterSave 创建或更新后从数据库重新读取再建索引：Updates局部更新时模型上可能不是最新值
+func (idx *invertedIndex) afterSave(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil {
+		return
+	}
+	table := tx.Statement.Schema.Table
+	if table != "posts" && table != "comments" {
+		return
+	}
+	fresh := tx.Session(&gorm.Session{NewDB: true})
+	for _, id := range primaryKeys(tx) {
+		if table == "posts" {
+			var p Post
+			if err := fresh.First(&p, id).Error; err == nil {
+				idx.indexPost(&p)
+			}
+		} else {
+			var cm Comment
+			if err := fresh.First(&cm, id).Error; err == nil {
+				idx.indexComment(&cm)
+			}
+		}
+	}
+}
+
+// afterDelete 删除后移出索引
+func (idx *invertedIndex) afterDelete(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil {
+		return
+	}
+	docType := map[string]string{"posts": "post", "comments": "comment"}[tx.Statement.Schema.Table]
+	if docType == "" {
+		return
+	}
+	for _, id := range primaryKeys(tx) {
+		idx.remove(docKey{docType, id})
+	}
+}
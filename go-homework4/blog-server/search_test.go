@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// 搜索结果要当作HTML显示高亮，两种搜索实现都必须转义原文，只保留<mark>标签
+var searchXSSCases = []struct {
+	name    string
+	title   string
+	content string
+}{
+	{"script标签", "golang <script>alert(1)</script>", "正文 golang <script>alert(document.cookie)</script>"},
+	{"图片onerror", `<img src=x onerror="alert(1)"> golang`, `golang <img src=x onerror="alert(1)">`},
+	{"伪造mark", "<mark onclick=alert(1)>golang</mark>", "golang &lt;已转义&gt; <mark>假的</mark>"},
+}
+
+// checkEscaped 去掉合法的<mark>标签后不能再有任何尖括号，并且确实有高亮
+func checkEscaped(t *testing.T, name, field, s string) {
+	t.Helper()
+	if !strings.Contains(s, highlightStart+"golang"+highlightStop) {
+		t.Errorf("%s: %s 没有高亮: %q", name, field, s)
+	}
+	rest := strings.NewReplacer(highlightStart, "", highlightStop, "").Replace(s)
+	if strings.ContainsAny(rest, "<>\"") {
+		t.Errorf("%s: %s 没有转义: %q", name, field, s)
+	}
+}
+
+func TestMemorySearchEscapesHTML(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range searchXSSCases {
+		repos := NewMemoryRepositories()
+		ms := &memorySearcher{idx: newInvertedIndex(), posts: repos.Posts, comments: repos.Comments}
+		post := Post{Title: tc.title, Content: tc.content, UserID: 1, Status: StatusPublished}
+		if err := repos.Posts.Create(ctx, &post); err != nil {
+			t.Fatal(err)
+		}
+		ms.idx.indexPost(&post)
+
+		results, _, err := ms.Search(ctx, &SearchQuery{Q: "golang", Type: "all", Page: 1, PageSize: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("%s: 搜索结果 %d 条，期望 1 条", tc.name, len(results))
+		}
+		checkEscaped(t, tc.name, "title", results[0].Title)
+		checkEscaped(t, tc.name, "snippet", results[0].Snippet)
+	}
+}
+
+// PostgreSQL的ts_headline不转义原文，这里模拟它的输出：原文不变，命中的词两边加上 pgHeadlineOpts 里的标记
+func TestPgHighlightEscapesHTML(t *testing.T) {
+	headline := func(s string) string {
+		return strings.ReplaceAll(s, "golang", pgMarkStart+"golang"+pgMarkStop)
+	}
+	for _, tc := range searchXSSCases {
+		checkEscaped(t, tc.name, "title", pgHighlight(headline(tc.title)))
+		checkEscaped(t, tc.name, "snippet", pgHighlight(headline(tc.content)))
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ====================== 文件存储：本地目录 / S3兼容存储 ======================
+// 上传接口只依赖Storage接口，换存储后端只需要改配置 uploads.storage
+// key由上传接口根据文件内容的哈希生成，形如 ab/ab12...ef.png，不包含用户输入
+
+const (
+	StorageLocal = "local" // 保存到本地目录，由本服务提供下载
+	StorageS3    = "s3"    // 保存到S3兼容的对象存储（AWS S3、MinIO等）
+)
+
+// DefaultLocalBaseURL 本地存储没有配置 uploads.base_url 时的访问地址前缀，由本服务直接提供下载
+const DefaultLocalBaseURL = "/uploads"
+
+// Storage 文件存储后端
+type Storage interface {
+	// Put 保存文件，key已存在时覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Delete 删除文件，文件不存在不算错误
+	Delete(ctx context.Context, key string) error
+	// URL 返回文件的访问地址
+	URL(key string) string
+}
+
+// NewStorage 按配置创建存储后端；uploads.base_url 为空时按存储后端取默认值
+func NewStorage(cfg UploadsConfig) (Storage, error) {
+	switch cfg.Storage {
+	case StorageLocal:
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = DefaultLocalBaseURL
+		}
+		return NewLocalStorage(cfg.Dir, baseURL)
+	case StorageS3:
+		return NewS3Storage(cfg.S3, cfg.BaseURL)
+	}
+	return nil, fmt.Errorf("不支持的存储后端: %s", cfg.Storage)
+}
+
+// ---------------------- 本地目录 ----------------------
+
+// LocalStorage 把文件保存在本地目录下，key中的 / 对应子目录
+type LocalStorage struct {
+	dir     string
+	baseURL string
+}
+
+func NewLocalStorage(dir, baseURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建上传目录失败: %w", err)
+	}
+	return &LocalStorage{dir: dir, baseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+func (s *LocalStorage) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+func (s *LocalStorage) Put(_ context.Context, key string, r io.Reader, _ int64, _ string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，避免并发下载时读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// ---------------------- S3兼容存储 ----------------------
+
+// S3Storage 把文件保存到S3兼容的对象存储，本地开发可以用MinIO代替
+type S3Storage struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+// NewS3Storage 连接对象存储，bucket不存在时自动创建
+// baseURL为空时用 endpoint/bucket/key 的路径形式访问（需要bucket允许公开读），一般配置成CDN地址
+func NewS3Storage(cfg S3Config, baseURL string) (*S3Storage, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建S3客户端失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("连接S3失败: %w", err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("创建bucket %s 失败: %w", cfg.Bucket, err)
+		}
+	}
+
+	if baseURL == "" {
+		scheme := "http"
+		if cfg.UseSSL {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + cfg.Endpoint + "/" + cfg.Bucket
+	}
+	return &S3Storage{client: client, bucket: cfg.Bucket, baseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	// 对象不存在时S3也返回成功
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3Storage) URL(key string) string {
+	return s.baseURL + "/" + key
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 只实现存储用到的几个S3接口：查询/创建bucket、上传对象、删除对象，代替MinIO
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]bool
+	objects map[string][]byte // bucket/key -> 内容
+	types   map[string]string // bucket/key -> 最近一次上传的Content-Type
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{buckets: map[string]bool{}, objects: map[string][]byte{}, types: map[string]string{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(path, "/")
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if !f.buckets[bucket] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			f.buckets[bucket] = true
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+		return
+	}
+	if !f.buckets[bucket] {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, err := readS3Body(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[path] = body
+		f.types[path] = r.Header.Get("Content-Type")
+		w.Header().Set("ETag", `"fake"`)
+	case http.MethodDelete:
+		delete(f.objects, path) // 和S3一样，对象不存在也返回成功
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// readS3Body 读出上传的内容；不走HTTPS时客户端用分块签名上传，要去掉每块前面的长度和签名
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var out bytes.Buffer
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		n, err := strconv.ParseInt(size, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return out.Bytes(), nil
+		}
+		if _, err := io.CopyN(&out, br, n); err != nil {
+			return nil, err
+		}
+		if _, err := br.Discard(2); err != nil { // 块后面的\r\n
+			return nil, err
+		}
+	}
+}
+
+func (f *fakeS3) object(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.objects[key]
+	return b, ok
+}
+
+func (f *fakeS3) hasBucket(bucket string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.buckets[bucket]
+}
+
+// testStorageContract 所有存储后端都要满足的行为；read读出存储里的文件，用来确认确实写进去了
+func testStorageContract(t *testing.T, s Storage, wantURL string, read func(key string) ([]byte, bool)) {
+	t.Helper()
+	ctx := context.Background()
+	key := "ab/ab12ef.txt"
+
+	put := func(content string) {
+		t.Helper()
+		if err := s.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+			t.Fatalf("保存文件失败: %v", err)
+		}
+		if got, ok := read(key); !ok || string(got) != content {
+			t.Fatalf("保存后读出 %q，期望 %q", got, content)
+		}
+	}
+	put("hello")
+	put("hello again") // key已存在时覆盖
+
+	if got := s.URL(key); got != wantURL {
+		t.Errorf("URL = %q，期望 %q", got, wantURL)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("删除文件失败: %v", err)
+	}
+	if _, ok := read(key); ok {
+		t.Error("删除后文件还在")
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("删除不存在的文件不算错误，得到 %v", err)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	cfg := defaultConfig().Uploads
+	cfg.Dir = dir
+	s, err := NewStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorageContract(t, s, "/uploads/ab/ab12ef.txt", func(key string) ([]byte, bool) {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(key)))
+		return b, err == nil
+	})
+}
+
+func TestS3Storage(t *testing.T) {
+	fake, srv := newFakeS3(t)
+	cfg := defaultConfig().Uploads
+	cfg.Storage = StorageS3
+	cfg.S3 = S3Config{Endpoint: strings.TrimPrefix(srv.URL, "http://"), Bucket: "blog", AccessKey: "key", SecretKey: "secret", Region: "us-east-1"}
+
+	// 没有配置 base_url 时访问地址是 endpoint/bucket/key，bucket不存在时自动创建
+	s, err := NewStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fake.hasBucket("blog") {
+		t.Fatal("没有自动创建bucket")
+	}
+	testStorageContract(t, s, srv.URL+"/blog/ab/ab12ef.txt", func(key string) ([]byte, bool) {
+		return fake.object("blog/" + key)
+	})
+	fake.mu.Lock()
+	ct := fake.types["blog/ab/ab12ef.txt"]
+	fake.mu.Unlock()
+	if ct != "text/plain" {
+		t.Errorf("上传时的Content-Type是 %q，期望 text/plain", ct)
+	}
+
+	// 配置了 base_url（如CDN地址）时用它拼访问地址
+	cfg.BaseURL = "https://cdn.example.com/"
+	s, err = NewStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.URL("ab/ab12ef.txt"); got != "https://cdn.example.com/ab/ab12ef.txt" {
+		t.Errorf("URL = %q", got)
+	}
+}
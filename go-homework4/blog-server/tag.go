@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 标签和分类 ======================
+// 一篇文章可以有多个标签（多对多，关联表post_tags），最多属于一个分类
+// 创建/更新文章时按名称传标签和分类，不存在的自动创建；名称统一规范化，避免 "Go"、" go " 变成两个标签
+
+const (
+	MaxTagsPerPost = 10 // 每篇文章最多几个标签
+	MaxTagNameLen  = 50 // 标签/分类名称最大长度（字符数）
+)
+
+// Tag 标签表
+type Tag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Name      string    `gorm:"unique;not null;type:varchar(50)" json:"name"` // 规范化后的名称
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Category 分类表
+type Category struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Name      string    `gorm:"unique;not null;type:varchar(50)" json:"name"` // 规范化后的名称
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagCount 标签及其下的文章数
+type TagCount struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	PostCount int64  `json:"post_count"`
+}
+
+// CategoryCount 分类及其下的文章数
+type CategoryCount struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	PostCount int64  `json:"post_count"`
+}
+
+// normalizeName 规范化标签/分类名称：去掉首尾空白、转小写、中间连续空白换成一个 "-"
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+// normalizeTagNames 规范化并去重，保持传入顺序；空名称忽略
+func normalizeTagNames(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	out := []string{}
+	for _, name := range names {
+		n := normalizeName(name)
+		if n == "" || seen[n] {
+			continue
+		}
+		if utf8.RuneCountInString(n) > MaxTagNameLen {
+			return nil, invalidField("tags", "max", fmt.Sprintf("%q 超过%d个字符", n, MaxTagNameLen))
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	if len(out) > MaxTagsPerPost {
+		return nil, invalidField("tags", "max", fmt.Sprintf("每篇文章最多%d个", MaxTagsPerPost))
+	}
+	return out, nil
+}
+
+// PostRequest 创建/更新文章的请求体
+// 更新时：tags不传表示不修改，传空数组表示清空；category不传表示不修改，传空字符串表示取消分类
+type PostRequest struct {
+	Title    string   `json:"title" binding:"max=100"`
+	Content  string   `json:"content"`
+	Tags     []string `json:"tags"`     // 标签名称列表
+	Category *string  `json:"category"` // 分类名称
+	Format   string   `json:"format"`   // 内容格式，见 render.go；创建时默认markdown，更新时不传表示不修改
+
+	// 状态和定时发布时间，见 post_status.go；创建时不传表示直接发布，更新时不传表示不修改
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// validateCreate 创建文章时标题和内容必填，更新时不传表示不修改
+func (req *PostRequest) validateCreate() error {
+	if req.Title == "" {
+		return invalidField("title", "required", "不能为空")
+	}
+	if req.Content == "" {
+		return invalidField("content", "required", "不能为空")
+	}
+	return nil
+}
+
+// applyTaxonomy 按请求里的标签和分类名称设置文章的 Tags/CategoryID，不存在的自动创建
+// 请求里没传的字段保持文章原值
+func (s *Server) applyTaxonomy(ctx context.Context, post *Post, req *PostRequest) error {
+	if req.Tags != nil {
+		names, err := normalizeTagNames(req.Tags)
+		if err != nil {
+			return err
+		}
+		tags, err := s.Tags.FindOrCreateTags(ctx, names)
+		if err != nil {
+			return err
+		}
+		post.Tags = tags
+	}
+	if req.Category != nil {
+		post.CategoryID, post.Category = nil, nil
+		if name := normalizeName(*req.Category); name != "" {
+			if utf8.RuneCountInString(name) > MaxTagNameLen {
+				return invalidField("category", "max", fmt.Sprintf("超过%d个字符", MaxTagNameLen))
+			}
+			category, err := s.Tags.FindOrCreateCategory(ctx, name)
+			if err != nil {
+				return err
+			}
+			post.CategoryID, post.Category = &category.ID, category
+		}
+	}
+	return nil
+}
+
+// taxonomyError applyTaxonomy的参数错误原样返回（400），其它错误记日志后按500处理
+func taxonomyError(err error) error {
+	if _, ok := apperr.As(err); ok {
+		return err
+	}
+	log.Errorf("保存标签/分类失败: %v", err)
+	return apperr.Internal("保存标签/分类失败")
+}
+
+// ListTags 获取标签列表 GET /api/tags 【无需登录】
+// 按文章数从多到少排列，没有文章的标签不返回
+func (s *Server) ListTags(c *gin.Context) {
+	tags, err := s.Tags.ListTags(c.Request.Context())
+	if err != nil {
+		log.Errorf("获取标签列表失败: %v", err)
+		fail(c, apperr.Internal("获取标签失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "获取成功", "data": tags})
+}
+
+// ListCategories 获取分类列表 GET /api/categories 【无需登录】
+// 按文章数从多到少排列，没有文章的分类不返回
+func (s *Server) ListCategories(c *gin.Context) {
+	categories, err := s.Tags.ListCategories(c.Request.Context())
+	if err != nil {
+		log.Errorf("获取分类列表失败: %v", err)
+		fail(c, apperr.Internal("获取分类失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "获取成功", "data": categories})
+}
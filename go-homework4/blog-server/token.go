@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ====================== 刷新令牌、登出、令牌吊销 ======================
+// 登录后下发一对令牌：短期的access token(JWT) + 长期的refresh token(随机串，只在数据库存哈希)
+// 每次刷新都会作废旧的refresh token并签发新的一对（轮换）；同一次登录衍生出的所有refresh token属于同一个family
+// 如果已经用过的refresh token被再次使用，说明令牌可能泄露，整个family连同签发过的access token全部吊销
+
+// RefreshToken 刷新令牌表
+type RefreshToken struct {
+	ID        uint       `gorm:"primarykey"`
+	TokenHash string     `gorm:"uniqueIndex;not null;type:varchar(64)"` // 令牌的sha256，数据库不存明文
+	UserID    uint       `gorm:"index;not null"`
+	FamilyID  string     `gorm:"index;not null;type:varchar(32)"` // 同一次登录的令牌链
+	AccessJTI string     `gorm:"index;type:varchar(32)"`          // 与它一起签发的access token的jti
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // 已用于刷新的时间，非空表示已轮换
+	RevokedAt *time.Time // 被吊销的时间
+	CreatedAt time.Time
+}
+
+// RevokedToken 已吊销的access token，AuthMiddleware按jti检查；过期后的记录可以清理掉
+type RevokedToken struct {
+	JTI       string    `gorm:"primarykey;type:varchar(32)"`
+	ExpiresAt time.Time `gorm:"index;not null"` // 对应access token的过期时间
+	CreatedAt time.Time
+}
+
+// randomString 生成n字节的安全随机串
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // 系统随机源不可用，无法继续
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newJTI() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenPair 登录和刷新接口返回的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // access token有效期，单位秒
+}
+
+// issueTokenPair 签发一对令牌并保存refresh token；familyID为空时开启新的family
+func (s *Server) issueTokenPair(c *gin.Context, user *User, familyID string) (*TokenPair, error) {
+	jti := newJTI()
+	access, err := s.GenerateToken(user.ID, user.Username, user.Role, jti)
+	if err != nil {
+		return nil, err
+	}
+	if familyID == "" {
+		familyID = newJTI()
+	}
+	refresh := randomString(32)
+	rt := RefreshToken{
+		TokenHash: hashToken(refresh),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		AccessJTI: jti,
+		ExpiresAt: time.Now().Add(s.cfg.JWT.RefreshTTL.Duration),
+	}
+	if err := s.Tokens.CreateRefreshToken(c.Request.Context(), &rt); err != nil {
+		return nil, err
+	}
+	return &TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: int64(s.cfg.JWT.AccessTTL.Seconds())}, nil
+}
+
+// refreshRequest 刷新令牌的请求体
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshTokenHandler 刷新令牌 POST /api/token/refresh 【无需access token，凭refresh token换新令牌】
+func (s *Server) RefreshTokenHandler(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, bindError(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	rt, err := s.Tokens.FindRefreshTokenByHash(ctx, hashToken(req.RefreshToken))
+	if err == nil && rt.ExpiresAt.Before(time.Now()) {
+		err = ErrNotFound
+	}
+	if errors.Is(err, ErrNotFound) {
+		fail(c, errRefreshInvalid)
+		return
+	}
+	if err != nil {
+		log.Errorf("查询refresh token失败: %v", err)
+		fail(c, apperr.Internal("刷新令牌失败"))
+		return
+	}
+
+	// 原子地标记为已使用；已轮换或已吊销的令牌再次出现，说明可能被盗用，吊销整个family
+	ok, err := s.Tokens.MarkRefreshTokenUsed(ctx, rt.ID, time.Now())
+	if err != nil {
+		log.Errorf("更新refresh token失败: %v", err)
+		fail(c, apperr.Internal("刷新令牌失败"))
+		return
+	}
+	if !ok {
+		if err := s.Tokens.RevokeFamily(ctx, rt.FamilyID, s.cfg.JWT.AccessTTL.Duration); err != nil {
+			log.Errorf("吊销令牌族失败: %v", err)
+			fail(c, apperr.Internal("刷新令牌失败"))
+			return
+		}
+		log.Warnf("检测到refresh token重复使用，已吊销令牌族: 用户ID:%d family:%s", rt.UserID, rt.FamilyID)
+		fail(c, errRefreshRevoked)
+		return
+	}
+
+	user, err := s.Users.FindByID(ctx, rt.UserID)
+	if errors.Is(err, ErrNotFound) {
+		fail(c, errRefreshInvalid)
+		return
+	}
+	if err != nil {
+		log.Errorf("查询用户失败: %v", err)
+		fail(c, apperr.Internal("刷新令牌失败"))
+		return
+	}
+	pair, err := s.issueTokenPair(c, user, rt.FamilyID)
+	if err != nil {
+		log.Errorf("签发令牌失败: %v", err)
+		fail(c, apperr.Internal("刷新令牌失败"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "刷新成功", "data": pair})
+}
+
+// Logout 登出 POST /api/logout 【需要登录】
+// 吊销当前access token，并吊销与它同一次登录的整个refresh token族
+func (s *Server) Logout(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := s.revokeSession(ctx, c.GetString("jti"), c.GetTime("tokenExpiresAt")); err != nil {
+		log.Errorf("登出失败: %v", err)
+		fail(c, apperr.Internal("登出失败"))
+		return
+	}
+
+	// 顺手清理已过期的吊销记录，过期的令牌本身已经无法通过校验
+	if err := s.Tokens.PruneRevokedTokens(ctx, time.Now()); err != nil {
+		log.Errorf("清理过期吊销记录失败: %v", err)
+	}
+	log.Infof("用户ID:%d 登出成功", c.GetUint("userID"))
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "登出成功"})
+}
+
+// revokeSession 吊销access token及其所属的令牌族
+func (s *Server) revokeSession(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := s.Tokens.RevokeAccessToken(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+	rt, err := s.Tokens.FindRefreshTokenByAccessJTI(ctx, jti)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.Tokens.RevokeFamily(ctx, rt.FamilyID, s.cfg.JWT.AccessTTL.Duration)
+}
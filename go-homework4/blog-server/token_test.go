@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// loginPair 登录并返回access token和refresh token
+func (sc specClient) loginPair(name string) (access, refresh string) {
+	sc.t.Helper()
+	resp := sc.call("POST", "/api/login", "", gin.H{"username": name, "password": "secret"}, 200)
+	return resp["token"].(string), resp["refresh_token"].(string)
+}
+
+// refresh 用refresh token换一对新令牌
+func (sc specClient) refresh(refresh string) (string, string) {
+	sc.t.Helper()
+	data := sc.call("POST", "/api/token/refresh", "", gin.H{"refresh_token": refresh}, 200)["data"].(map[string]interface{})
+	return data["token"].(string), data["refresh_token"].(string)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	_, r := specServer(t)
+	sc := specClient{t, r}
+	sc.login("alice")
+	_, rt1 := sc.loginPair("alice")
+
+	// 每次刷新都换一个新的refresh token，新旧access token都能用
+	at2, rt2 := sc.refresh(rt1)
+	if rt2 == rt1 {
+		t.Fatal("刷新后refresh token没有轮换")
+	}
+	at3, _ := sc.refresh(rt2)
+	sc.call("GET", "/api/notifications", at2, nil, 200)
+	sc.call("GET", "/api/notifications", at3, nil, 200)
+
+	sc.call("POST", "/api/token/refresh", "", gin.H{"refresh_token": "nope"}, 401)
+	sc.call("POST", "/api/token/refresh", "", gin.H{}, 400)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	_, r := specServer(t)
+	sc := specClient{t, r}
+	sc.login("alice")
+	_, rt1 := sc.loginPair("alice")
+	otherAccess, otherRefresh := sc.loginPair("alice") // 另一次登录，属于另一个family
+	at2, rt2 := sc.refresh(rt1)
+
+	// 已经用过的rt1再次出现：拒绝，并且整个family都作废
+	resp := sc.call("POST", "/api/token/refresh", "", gin.H{"refresh_token": rt1}, 401)
+	if code := errorCode(resp); code != errRefreshRevoked.Code {
+		t.Errorf("重复使用refresh token的错误码 %s，期望 %s", code, errRefreshRevoked.Code)
+	}
+	resp = sc.call("POST", "/api/token/refresh", "", gin.H{"refresh_token": rt2}, 401)
+	if code := errorCode(resp); code != errRefreshRevoked.Code {
+		t.Errorf("同一family里最新的refresh token也应该作废，错误码 %s", code)
+	}
+	resp = sc.call("GET", "/api/notifications", at2, nil, 401)
+	if code := errorCode(resp); code != errTokenRevoked.Code {
+		t.Errorf("同一family签发的access token也应该作废，错误码 %s", code)
+	}
+
+	// 另一次登录不受影响
+	sc.call("GET", "/api/notifications", otherAccess, nil, 200)
+	sc.refresh(otherRefresh)
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	_, r := specServer(t)
+	sc := specClient{t, r}
+	sc.login("alice")
+	at1, rt1 := sc.loginPair("alice")
+	at2, rt2 := sc.refresh(rt1)
+	otherAccess, _ := sc.loginPair("alice")
+
+	sc.call("POST", "/api/logout", at2, nil, 200)
+	resp := sc.call("GET", "/api/notifications", at2, nil, 401)
+	if code := errorCode(resp); code != errTokenRevoked.Code {
+		t.Errorf("登出后access token的错误码 %s，期望 %s", code, errTokenRevoked.Code)
+	}
+	sc.call("POST", "/api/logout", at2, nil, 401)
+	// 同一次登录签发过的令牌全部作废，另一次登录不受影响
+	sc.call("GET", "/api/notifications", at1, nil, 401)
+	sc.call("POST", "/api/token/refresh", "", gin.H{"refresh_token": rt2}, 401)
+	sc.call("GET", "/api/notifications", otherAccess, nil, 200)
+}
+
+func TestRevokedJTIRejected(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	sc.login("alice")
+	access, _ := sc.loginPair("alice")
+	sc.call("GET", "/api/notifications", access, nil, 200)
+
+	claims := new(JWTClaims)
+	if _, _, err := new(jwt.Parser).ParseUnverified(access, claims); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := s.Tokens.RevokeAccessToken(ctx, claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		t.Fatal(err)
+	}
+	resp := sc.call("GET", "/api/notifications", access, nil, 401)
+	if code := errorCode(resp); code != errTokenRevoked.Code {
+		t.Errorf("吊销后的错误码 %s，期望 %s", code, errTokenRevoked.Code)
+	}
+
+	// 没有jti的令牌无法吊销，一律不认
+	noJTI, err := s.GenerateToken(claims.UserID, claims.Username, claims.Role, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.call("GET", "/api/notifications", noJTI, nil, 401)
+
+	// 过期的吊销记录清理掉，没过期的保留
+	if err := s.Tokens.PruneRevokedTokens(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := s.Tokens.IsAccessTokenRevoked(ctx, claims.Id); err != nil || !revoked {
+		t.Errorf("没过期的吊销记录被清理了: %v %v", revoked, err)
+	}
+}
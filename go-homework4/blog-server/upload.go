@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	_ "golang.org/x/image/webp"
+	_ "image/gif" // 注册gif解码器
+
+	"blog-server/apperr"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/draw"
+)
+
+// ====================== 文件上传：图片/附件 + 去重 + 缩略图 ======================
+// 文件类型按内容检测，不相信客户端传的Content-Type和扩展名
+// 文件按内容的SHA-256保存，同样的内容只存一份；同一个用户重复上传直接返回已有的附件
+// 图片会额外生成一张缩略图，宽度不超过 uploads.thumb_width
+
+const maxImagePixels = 50_000_000 // 图片最大像素数，防止解码超大图片占满内存
+
+// Attachment 附件表，上传时可以关联到自己的文章
+type Attachment struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_attachment_user_hash" json:"user_id"` // 上传者
+	PostID      *uint     `gorm:"index" json:"post_id"`                                         // 关联的文章，可为空
+	Hash        string    `gorm:"not null;type:varchar(64);uniqueIndex:idx_attachment_user_hash;index" json:"hash"`
+	Filename    string    `gorm:"not null;type:varchar(255)" json:"filename"` // 上传时的文件名，只用于展示
+	ContentType string    `gorm:"not null;type:varchar(100)" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	Width       int       `json:"width,omitempty"` // 图片的宽高，其它文件为0
+	Height      int       `json:"height,omitempty"`
+	Key         string    `gorm:"not null;type:varchar(255)" json:"-"` // 存储里的key
+	ThumbKey    string    `gorm:"type:varchar(255)" json:"-"`          // 缩略图的key，只有图片有
+	URL         string    `gorm:"-" json:"url"`
+	ThumbURL    string    `gorm:"-" json:"thumb_url,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// fileExts 各MIME类型保存时用的扩展名
+var fileExts = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// withURLs 按当前的存储后端填上访问地址
+func (s *Server) withURLs(a *Attachment) *Attachment {
+	a.URL = s.storage.URL(a.Key)
+	if a.ThumbKey != "" {
+		a.ThumbURL = s.storage.URL(a.ThumbKey)
+	}
+	return a
+}
+
+// UploadFile 上传文件 POST /api/uploads 【需要登录】
+// multipart表单：file=文件，post_id=关联的文章ID（可选，只能是自己的文章）
+func (s *Server) UploadFile(c *gin.Context) {
+	if s.storage == nil {
+		fail(c, errStorageDisabled)
+		return
+	}
+	cfg := s.cfg.Uploads
+	userID := c.GetUint("userID")
+	ctx := c.Request.Context()
+
+	// 整个请求体限制在文件大小上限加1MB（留给表单的其它部分），超过直接断开读取
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxSize+1<<20)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			fail(c, errFileTooLarge.WithMessage("文件不能超过"+humanSize(cfg.MaxSize)))
+			return
+		}
+		fail(c, errFileMissing)
+		return
+	}
+	if fh.Size > cfg.MaxSize {
+		fail(c, errFileTooLarge.WithMessage("文件不能超过"+humanSize(cfg.MaxSize)))
+		return
+	}
+
+	var postID *uint
+	if v := c.PostForm("post_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			fail(c, errInvalidPostID)
+			return
+		}
+		post, err := s.Posts.FindByID(ctx, uint(id))
+		if err != nil || !canViewPost(c, post) {
+			fail(c, errPostNotFound)
+			return
+		}
+		if post.UserID != userID {
+			fail(c, errUploadForbidden)
+			return
+		}
+		postID = &post.ID
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		log.Errorf("读取上传文件失败: %v", err)
+		fail(c, apperr.Internal("上传失败"))
+		return
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		log.Errorf("读取上传文件失败: %v", err)
+		fail(c, apperr.Internal("上传失败"))
+		return
+	}
+
+	// 按文件内容检测类型
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if !allowedType(cfg.AllowedTypes, contentType) {
+		fail(c, errUnsupportedType.WithMessage("不支持的文件类型："+contentType))
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	// 同一个用户重复上传同样的内容，直接返回已有的附件；已有的附件关联在另一篇文章上时不能再关联到这篇
+	if existing, err := s.Attachments.FindByUserHash(ctx, userID, hash); err == nil {
+		if postID != nil && existing.PostID != nil && *existing.PostID != *postID {
+			fail(c, errAttachmentOtherPost.WithMessage(fmt.Sprintf("同样的文件已经是文章ID:%d 的附件（附件ID:%d）", *existing.PostID, existing.ID)))
+			return
+		}
+		if postID != nil && existing.PostID == nil {
+			if err := s.Attachments.SetPost(ctx, existing, postID); err != nil {
+				log.Errorf("关联附件失败: %v", err)
+				fail(c, apperr.Internal("上传失败"))
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "文件已存在", "data": s.withURLs(existing)})
+		return
+	} else if !errors.Is(err, ErrNotFound) {
+		log.Errorf("查询附件失败: %v", err)
+		fail(c, apperr.Internal("上传失败"))
+		return
+	}
+
+	a := Attachment{
+		UserID:      userID,
+		PostID:      postID,
+		Hash:        hash,
+		Filename:    displayName(fh.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Key:         hash[:2] + "/" + hash + fileExts[contentType],
+	}
+	var thumb *thumbnail
+	if strings.HasPrefix(contentType, "image/") {
+		if thumb, err = makeThumbnail(data, cfg.ThumbWidth); err != nil {
+			fail(c, errInvalidImage.WithMessage("图片无法解析："+err.Error()))
+			return
+		}
+		a.Width, a.Height = thumb.width, thumb.height
+		a.ThumbKey = "thumbs/" + hash[:2] + "/" + hash + thumb.ext
+	}
+
+	// 其它用户上传过同样的内容时文件可能已经在存储里了，但它的附件随时可能被删掉、连带删除文件，
+	// 所以总是重新写一遍；key由内容决定，重复写入结果不变
+	if err := s.storeFiles(ctx, &a, data, thumb); err != nil {
+		log.Errorf("保存上传文件失败: %v", err)
+		fail(c, apperr.Internal("上传失败"))
+		return
+	}
+
+	if err := s.Attachments.Create(ctx, &a); err != nil {
+		// 同一个用户并发上传同样的内容，以先保存的为准
+		if errors.Is(err, ErrDuplicate) {
+			if existing, ferr := s.Attachments.FindByUserHash(ctx, userID, hash); ferr == nil {
+				c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "文件已存在", "data": s.withURLs(existing)})
+				return
+			}
+		}
+		log.Errorf("保存附件失败: %v", err)
+		fail(c, apperr.Internal("上传失败"))
+		return
+	}
+
+	log.Infof("用户ID:%d 上传文件成功，附件ID:%d，类型:%s，大小:%d", userID, a.ID, a.ContentType, a.Size)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "上传成功！", "data": s.withURLs(&a)})
+}
+
+// storeFiles 把原文件和缩略图写入存储
+func (s *Server) storeFiles(ctx context.Context, a *Attachment, data []byte, thumb *thumbnail) error {
+	if err := s.storage.Put(ctx, a.Key, bytes.NewReader(data), int64(len(data)), a.ContentType); err != nil {
+		return err
+	}
+	if thumb != nil {
+		return s.storage.Put(ctx, a.ThumbKey, bytes.NewReader(thumb.data), int64(len(thumb.data)), thumb.contentType)
+	}
+	return nil
+}
+
+// ListPostAttachments 获取文章的附件 GET /api/posts/:id/attachments 【无需登录，未发布文章只有作者可看】
+func (s *Server) ListPostAttachments(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, errInvalidPostID)
+		return
+	}
+	post, err := s.Posts.FindByID(c.Request.Context(), uint(id))
+	if err != nil || !canViewPost(c, post) {
+		fail(c, errPostNotFound)
+		return
+	}
+	attachments, err := s.Attachments.ListByPost(c.Request.Context(), post.ID)
+	if err != nil {
+		log.Errorf("获取附件列表失败: %v", err)
+		fail(c, apperr.Internal("获取附件失败"))
+		return
+	}
+	if s.storage != nil {
+		for i := range attachments {
+			s.withURLs(&attachments[i])
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "获取成功", "data": attachments})
+}
+
+// DeleteAttachment 删除附件 DELETE /api/attachments/:id 【需要登录+只有上传者或版主可删除】
+// 没有其它附件引用同样的内容时，存储里的文件也一起删除
+func (s *Server) DeleteAttachment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, errInvalidAttachmentID)
+		return
+	}
+	ctx := c.Request.Context()
+	a, err := s.Attachments.FindByID(ctx, uint(id))
+	if err != nil {
+		fail(c, errAttachmentNotFound)
+		return
+	}
+	ok, privileged := authorize(c, a.UserID, PermDeleteAnyPost)
+	if !ok {
+		fail(c, errAttachmentForbidden)
+		return
+	}
+	if err := s.Attachments.Delete(ctx, a); err != nil {
+		log.Errorf("删除附件失败: %v", err)
+		fail(c, apperr.Internal("删除附件失败"))
+		return
+	}
+
+	// 文件删除失败只记日志，附件记录已经删掉了
+	if inUse, err := s.Attachments.HashInUse(ctx, a.Hash); err != nil {
+		log.Errorf("查询附件失败: %v", err)
+	} else if !inUse && s.storage != nil {
+		for _, key := range []string{a.Key, a.ThumbKey} {
+			if key == "" {
+				continue
+			}
+			if err := s.storage.Delete(ctx, key); err != nil {
+				log.Errorf("删除文件 %s 失败: %v", key, err)
+			}
+		}
+	}
+	if privileged {
+		s.audit(c, AuditAttachmentDelete, "attachment", a.ID, "上传者ID:"+strconv.FormatUint(uint64(a.UserID), 10))
+	}
+	log.Infof("用户ID:%d 删除附件成功，附件ID:%d", c.GetUint("userID"), a.ID)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "附件删除成功！"})
+}
+
+func allowedType(allowed []string, contentType string) bool {
+	for _, t := range allowed {
+		if strings.EqualFold(t, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// displayName 只保留文件名本身，去掉客户端可能带上的路径，过长的截断
+func displayName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		return "file"
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+func humanSize(n int64) string {
+	if n >= 1<<20 && n%(1<<20) == 0 {
+		return fmt.Sprintf("%dMB", n>>20)
+	}
+	if n >= 1<<10 && n%(1<<10) == 0 {
+		return fmt.Sprintf("%dKB", n>>10)
+	}
+	return fmt.Sprintf("%d字节", n)
+}
+
+// ---------------------- 缩略图 ----------------------
+
+type thumbnail struct {
+	data          []byte
+	contentType   string
+	ext           string
+	width, height int // 原图的宽高
+}
+
+// makeThumbnail 生成宽度不超过maxWidth的缩略图，原图更窄时只重新编码不缩放
+// PNG和GIF缩成PNG以保留透明背景，其它缩成JPEG
+func makeThumbnail(data []byte, maxWidth int) (*thumbnail, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("图片尺寸 %dx%d 超出限制", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := cfg.Width, cfg.Height
+	if w > maxWidth {
+		w, h = maxWidth, max(1, h*maxWidth/w)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	keepAlpha := format == "png" || format == "gif"
+	if !keepAlpha {
+		// JPEG没有透明通道，透明的地方铺成白色
+		draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	t := &thumbnail{width: cfg.Width, height: cfg.Height}
+	var buf bytes.Buffer
+	if keepAlpha {
+		err = png.Encode(&buf, dst)
+		t.contentType, t.ext = "image/png", ".png"
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+		t.contentType, t.ext = "image/jpeg", ".jpg"
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.data = buf.Bytes()
+	return t, nil
+}
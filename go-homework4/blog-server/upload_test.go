@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upload 用multipart表单上传文件，postID为0时不关联文章
+func (sc specClient) upload(token string, data []byte, postID uint, want int) map[string]interface{} {
+	sc.t.Helper()
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, _ := mw.CreateFormFile("file", "dot.png")
+	fw.Write(data)
+	if postID != 0 {
+		mw.WriteField("post_id", fmt.Sprint(postID))
+	}
+	mw.Close()
+	req := httptest.NewRequest("POST", "/api/uploads", &form)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	var resp map[string]interface{}
+	if err := json.Unmarshal(sc.send(req, token, want).Body.Bytes(), &resp); err != nil {
+		sc.t.Fatal(err)
+	}
+	return resp
+}
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	return img.Bytes()
+}
+
+func TestUploadDedupAcrossPosts(t *testing.T) {
+	_, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	post1 := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "一", "content": "内容"}, 200), "id")
+	post2 := sc.id(sc.call("POST", "/api/posts", alice, gin.H{"title": "二", "content": "内容"}, 200), "id")
+	img := testPNG(t)
+
+	// 先不关联文章，再上传同样的内容时关联到post1，还是同一个附件
+	first := sc.id(sc.upload(alice, img, 0, 200), "id")
+	if id := sc.id(sc.upload(alice, img, post1, 200), "id"); id != first {
+		t.Fatalf("重复上传得到新附件 %d，期望 %d", id, first)
+	}
+	if id := sc.id(sc.upload(alice, img, post1, 200), "id"); id != first {
+		t.Fatalf("重复上传得到新附件 %d，期望 %d", id, first)
+	}
+
+	// 已经是post1的附件，不能悄悄返回post1的附件当作post2的
+	resp := sc.upload(alice, img, post2, 409)
+	if code := errorCode(resp); code != errAttachmentOtherPost.Code {
+		t.Errorf("错误码 %s，期望 %s", code, errAttachmentOtherPost.Code)
+	}
+	list := sc.call("GET", fmt.Sprint("/api/posts/", post2, "/attachments"), "", nil, 200)
+	if n := len(list["data"].([]interface{})); n != 0 {
+		t.Errorf("post2 有 %d 个附件，期望 0", n)
+	}
+}
+
+func TestUploadSharedBlob(t *testing.T) {
+	s, r := specServer(t)
+	sc := specClient{t, r}
+	alice := sc.login("alice")
+	bob := sc.login("bob")
+	img := testPNG(t)
+	dir := s.storage.(*LocalStorage).dir
+	// exists 附件的原文件和缩略图是否都在存储里；key不在响应里，从仓储里取
+	var keys []string
+	exists := func() bool {
+		t.Helper()
+		for _, key := range keys {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(key))); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	a := sc.upload(alice, img, 0, 200)
+	// 模拟alice的附件正在被删除、文件已经删掉但记录还在：bob上传同样的内容时要重新写入文件
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	b := sc.upload(bob, img, 0, 200)
+	att, err := s.Attachments.FindByID(context.Background(), sc.id(b, "id"))
+	if err != nil || att.ThumbKey == "" {
+		t.Fatalf("附件不对: %+v %v", att, err)
+	}
+	keys = []string{att.Key, att.ThumbKey}
+	if !exists() {
+		t.Fatal("其它用户上传过同样的内容时没有重新写入文件")
+	}
+
+	// 还有别的附件引用同样的内容时不删文件，最后一个引用删掉后才删
+	sc.call("DELETE", fmt.Sprint("/api/attachments/", sc.id(a, "id")), alice, nil, 200)
+	if !exists() {
+		t.Fatal("其它附件还在用，文件被删了")
+	}
+	sc.call("DELETE", fmt.Sprint("/api/attachments/", sc.id(b, "id")), bob, nil, 200)
+	if exists() {
+		t.Error("最后一个附件删除后文件还在")
+	}
+}